@@ -21,6 +21,8 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	FileTransferService_TransferFile_FullMethodName = "/filetransfer.FileTransferService/TransferFile"
 	FileTransferService_GetChunk_FullMethodName     = "/filetransfer.FileTransferService/GetChunk"
+	FileTransferService_DeleteChunk_FullMethodName  = "/filetransfer.FileTransferService/DeleteChunk"
+	FileTransferService_NodeStats_FullMethodName    = "/filetransfer.FileTransferService/NodeStats"
 )
 
 // FileTransferServiceClient is the client API for FileTransferService service.
@@ -29,6 +31,8 @@ const (
 type FileTransferServiceClient interface {
 	TransferFile(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[FileChunk, TransferResponse], error)
 	GetChunk(ctx context.Context, in *ChunkRequest, opts ...grpc.CallOption) (*ChunkResponse, error)
+	DeleteChunk(ctx context.Context, in *DeleteChunkRequest, opts ...grpc.CallOption) (*DeleteChunkResponse, error)
+	NodeStats(ctx context.Context, in *NodeStatsRequest, opts ...grpc.CallOption) (*NodeStatsResponse, error)
 }
 
 type fileTransferServiceClient struct {
@@ -62,12 +66,34 @@ func (c *fileTransferServiceClient) GetChunk(ctx context.Context, in *ChunkReque
 	return out, nil
 }
 
+func (c *fileTransferServiceClient) DeleteChunk(ctx context.Context, in *DeleteChunkRequest, opts ...grpc.CallOption) (*DeleteChunkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteChunkResponse)
+	err := c.cc.Invoke(ctx, FileTransferService_DeleteChunk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) NodeStats(ctx context.Context, in *NodeStatsRequest, opts ...grpc.CallOption) (*NodeStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NodeStatsResponse)
+	err := c.cc.Invoke(ctx, FileTransferService_NodeStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // FileTransferServiceServer is the server API for FileTransferService service.
 // All implementations must embed UnimplementedFileTransferServiceServer
 // for forward compatibility.
 type FileTransferServiceServer interface {
 	TransferFile(grpc.ClientStreamingServer[FileChunk, TransferResponse]) error
 	GetChunk(context.Context, *ChunkRequest) (*ChunkResponse, error)
+	DeleteChunk(context.Context, *DeleteChunkRequest) (*DeleteChunkResponse, error)
+	NodeStats(context.Context, *NodeStatsRequest) (*NodeStatsResponse, error)
 	mustEmbedUnimplementedFileTransferServiceServer()
 }
 
@@ -84,6 +110,12 @@ func (UnimplementedFileTransferServiceServer) TransferFile(grpc.ClientStreamingS
 func (UnimplementedFileTransferServiceServer) GetChunk(context.Context, *ChunkRequest) (*ChunkResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetChunk not implemented")
 }
+func (UnimplementedFileTransferServiceServer) DeleteChunk(context.Context, *DeleteChunkRequest) (*DeleteChunkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteChunk not implemented")
+}
+func (UnimplementedFileTransferServiceServer) NodeStats(context.Context, *NodeStatsRequest) (*NodeStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NodeStats not implemented")
+}
 func (UnimplementedFileTransferServiceServer) mustEmbedUnimplementedFileTransferServiceServer() {}
 func (UnimplementedFileTransferServiceServer) testEmbeddedByValue()                             {}
 
@@ -130,6 +162,42 @@ func _FileTransferService_GetChunk_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _FileTransferService_DeleteChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).DeleteChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_DeleteChunk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).DeleteChunk(ctx, req.(*DeleteChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_NodeStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NodeStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).NodeStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_NodeStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).NodeStats(ctx, req.(*NodeStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // FileTransferService_ServiceDesc is the grpc.ServiceDesc for FileTransferService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -141,6 +209,14 @@ var FileTransferService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetChunk",
 			Handler:    _FileTransferService_GetChunk_Handler,
 		},
+		{
+			MethodName: "DeleteChunk",
+			Handler:    _FileTransferService_DeleteChunk_Handler,
+		},
+		{
+			MethodName: "NodeStats",
+			Handler:    _FileTransferService_NodeStats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -2,11 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"time"
 
 	"s3-example/internal/config"
 	"s3-example/internal/server"
@@ -32,20 +35,100 @@ func main() {
 		}
 	}()
 
-	err = registerWithTransferService(cfg)
+	if cfg.ScrubInterval > 0 {
+		scrubber := server.NewScrubber(storageDir, cfg.ServiceName,
+			time.Duration(cfg.ScrubInterval)*time.Second, cfg.ScrubBytesPerSecond,
+			func(chunkHash string) error {
+				return reportCorruptChunk(cfg, chunkHash)
+			})
+		go scrubber.Run(context.Background())
+	}
+
+	maintainRegistration(cfg)
+}
+
+// maintainRegistration registers the node with the transfer service,
+// retrying with backoff, and registers it again whenever the transfer
+// service reports it as unknown.
+func maintainRegistration(cfg *config.StorageServiceConfig) {
+	registerWithRetry(cfg)
+
+	ticker := time.NewTicker(time.Duration(cfg.RegistrationCheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		registered, err := isRegistered(cfg)
+		if err != nil {
+			log.Printf("Error checking registration with Transfer Service: %v", err)
+			continue
+		}
+		if !registered {
+			log.Printf("Transfer Service does not know %s, registering again", cfg.ServiceName)
+			registerWithRetry(cfg)
+		}
+	}
+}
+
+func registerWithRetry(cfg *config.StorageServiceConfig) {
+	backoff := time.Second
+	for {
+		err := registerWithTransferService(cfg)
+		if err == nil {
+			return
+		}
+
+		log.Printf("Error registering with Transfer Service: %v", err)
+		log.Printf("Retrying in %s...", backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func reportCorruptChunk(cfg *config.StorageServiceConfig, chunkHash string) error {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"service_name": cfg.ServiceName,
+		"chunk_hash":   chunkHash,
+	})
 	if err != nil {
-		log.Fatalf("Error registering with Transfer Service: %v", err)
+		return err
 	}
 
-	select {}
+	resp, err := http.Post(cfg.TransferServiceURL+"/corrupt-chunks", "application/json", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to report chunk, status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func isRegistered(cfg *config.StorageServiceConfig) (bool, error) {
+	resp, err := http.Get(cfg.TransferServiceURL + "/clients?service_name=" + url.QueryEscape(cfg.ServiceName))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
 }
 
 func registerWithTransferService(cfg *config.StorageServiceConfig) error {
 	grpcAddress := fmt.Sprintf("%s:%s", cfg.ServiceName, cfg.GRPCPort)
 
-	reqBody, err := json.Marshal(map[string]string{
+	reqBody, err := json.Marshal(map[string]interface{}{
 		"service_name": cfg.ServiceName,
 		"grpc_address": grpcAddress,
+		"weight":       cfg.Weight,
 	})
 	if err != nil {
 		return err
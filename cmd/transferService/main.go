@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"s3-example/internal/clients"
+	"s3-example/internal/cluster"
 	"s3-example/internal/config"
 	"s3-example/internal/handlers"
+	"s3-example/internal/placement"
+	"s3-example/internal/sse"
 	"s3-example/internal/storage"
 
 	"github.com/pressly/goose/v3"
@@ -37,19 +41,91 @@ func main() {
 		log.Fatalf("Error applying migrations: %v", err)
 	}
 
-	grpcClientManager := clients.NewGrpcClientManager()
+	grpcClientManager := clients.NewGrpcClientManager(dbManager)
 
-	fileHandler := handlers.NewFileHandler(cfg, grpcClientManager, dbManager)
+	storageNodes, err := dbManager.ListStorageNodes()
+	if err != nil {
+		log.Fatalf("Error loading storage nodes: %v", err)
+	}
+	for _, node := range storageNodes {
+		if err := grpcClientManager.RestoreClient(node.ServiceName, node.GRPCAddress, node.Weight, node.Draining); err != nil {
+			log.Printf("Error restoring storage node %s: %v", node.ServiceName, err)
+			continue
+		}
+		log.Printf("Restored storage node %s at %s", node.ServiceName, node.GRPCAddress)
+	}
+
+	purger := cluster.NewPurger(dbManager, grpcClientManager, time.Duration(cfg.PurgeInterval)*time.Second)
+	go purger.Run(context.Background())
+
+	sessionStore := storage.NewSessionStore(cfg.RedisAddr, time.Duration(cfg.SessionTTL)*time.Second)
+	defer sessionStore.Close()
+
+	go grpcClientManager.RunHeartbeats(context.Background(),
+		time.Duration(cfg.HeartbeatInterval)*time.Second,
+		time.Duration(cfg.NodeDeadAfter)*time.Second,
+		time.Duration(cfg.NodeDeregisterAfter)*time.Second)
+	go grpcClientManager.PollNodeStats(context.Background(), time.Duration(cfg.NodeStatsInterval)*time.Second)
+
+	// A nil interface, not a nil *LocalKMS, when no keyfile is configured.
+	var kms sse.KMS
+	if cfg.SSEMasterKeyFile != "" {
+		localKMS, err := sse.NewLocalKMS(cfg.SSEMasterKeyFile)
+		if err != nil {
+			log.Fatalf("Error loading master keys: %v", err)
+		}
+		kms = localKMS
+	}
+
+	fileHandler := handlers.NewFileHandler(cfg, grpcClientManager, dbManager, newChunkPlacement(cfg), kms)
 	registrationHandler := handlers.NewRegistrationHandler(grpcClientManager)
 
+	repairer := cluster.NewRepairer(dbManager, grpcClientManager, newChunkPlacement(cfg), fileHandler.ReadChunk,
+		cfg.ReplicationFactor, time.Duration(cfg.RepairInterval)*time.Second)
+	go repairer.Run(context.Background())
+
+	drainer := cluster.NewDrainer(dbManager, grpcClientManager, repairer, time.Duration(cfg.DrainInterval)*time.Second)
+	go drainer.Run(context.Background())
+
+	rebalancer := cluster.NewRebalancer(dbManager, grpcClientManager, newChunkPlacement(cfg), fileHandler.ReadChunk,
+		cfg.ReplicationFactor, time.Duration(cfg.RebalanceInterval)*time.Second,
+		cfg.RebalanceBatchSize, time.Duration(cfg.RebalancePause)*time.Second)
+	go rebalancer.Run(context.Background())
+
+	clusterHandler := handlers.NewClusterHandler(dbManager, grpcClientManager, repairer, drainer, rebalancer)
+	s3Handler := handlers.NewS3Handler(fileHandler, dbManager)
+	tusHandler := handlers.NewTusHandler(fileHandler, dbManager, sessionStore)
+
 	http.HandleFunc("/upload", fileHandler.UploadHandler)
 	http.HandleFunc("/download", fileHandler.DownloadHandler)
+	http.HandleFunc("/delete", fileHandler.DeleteHandler)
+	http.HandleFunc("/list", fileHandler.ListHandler)
+	http.HandleFunc("/stat", fileHandler.StatHandler)
+	http.Handle("/files/", tusHandler)
 
 	http.HandleFunc("/register", registrationHandler.RegisterHandler)
+	http.HandleFunc("/deregister", registrationHandler.DeregisterHandler)
 	http.HandleFunc("/clients", registrationHandler.GetClientsHandler)
+	http.HandleFunc("/corrupt-chunks", clusterHandler.CorruptChunkHandler)
+	http.HandleFunc("/admin/repair", clusterHandler.RepairHandler)
+	http.HandleFunc("/admin/drain", clusterHandler.DrainHandler)
+	http.HandleFunc("/admin/rebalance", clusterHandler.RebalanceHandler)
+
+	http.Handle("/", s3Handler)
 
 	fmt.Printf("HTTP server started on port %s\n", cfg.ServerPort)
 	if err := http.ListenAndServe(":"+cfg.ServerPort, nil); err != nil {
 		log.Fatalf("Error starting HTTP server: %v", err)
 	}
 }
+
+// newChunkPlacement creates the placement policy. Every user gets its own
+// instance, since the ring caches the node set it was last built for.
+func newChunkPlacement(cfg *config.TransferServiceConfig) placement.Placement {
+	switch cfg.PlacementPolicy {
+	case config.PlacementCapacity:
+		return placement.NewCapacity(cfg.HighWaterMark)
+	default:
+		return placement.NewRing(cfg.VirtualNodes)
+	}
+}
@@ -0,0 +1,43 @@
+package chunker
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// ModeFixed cuts the stream into chunks of the same size.
+	ModeFixed = "fixed"
+	// ModeFastCDC cuts the stream at content-defined boundaries, so an
+	// insertion or deletion only changes the chunks around it.
+	ModeFastCDC = "fastcdc"
+)
+
+// Chunker splits a stream into chunks. Next returns io.EOF after the last
+// chunk. Every returned slice is newly allocated and owned by the caller.
+type Chunker interface {
+	Next() ([]byte, error)
+}
+
+// Params holds the chunk sizes. Fixed chunking only uses ChunkSize.
+type Params struct {
+	ChunkSize int
+	MinSize   int
+	AvgSize   int
+	MaxSize   int
+}
+
+func New(mode string, r io.Reader, params Params) (Chunker, error) {
+	switch mode {
+	case ModeFixed, "":
+		return NewFixed(r, params.ChunkSize), nil
+	case ModeFastCDC:
+		return NewFastCDC(r, params.MinSize, params.AvgSize, params.MaxSize), nil
+	default:
+		return nil, fmt.Errorf("unknown chunking mode %q", mode)
+	}
+}
+
+func ValidMode(mode string) bool {
+	return mode == ModeFixed || mode == ModeFastCDC
+}
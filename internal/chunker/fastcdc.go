@@ -0,0 +1,118 @@
+package chunker
+
+import (
+	"io"
+	"math/bits"
+)
+
+// FastCDC implements content-defined chunking with a gear rolling hash and
+// normalized chunking (Xia et al., "FastCDC", USENIX ATC 2016). A boundary
+// is declared where the hash of the preceding bytes has its top bits clear.
+// Below AvgSize a stricter mask is used and above it a looser one, which
+// keeps most chunks close to the average. Chunks are never shorter than
+// MinSize, except the last one, nor longer than MaxSize.
+type FastCDC struct {
+	r       io.Reader
+	minSize int
+	avgSize int
+	maxSize int
+	maskS   uint64
+	maskL   uint64
+
+	buf []byte
+	eof bool
+}
+
+func NewFastCDC(r io.Reader, minSize, avgSize, maxSize int) *FastCDC {
+	avgSize = max(avgSize, 64)
+	minSize = min(max(minSize, 1), avgSize)
+	maxSize = max(maxSize, avgSize)
+
+	// Boundaries are on average 2^avgBits bytes apart.
+	avgBits := bits.Len(uint(avgSize)) - 1
+	return &FastCDC{
+		r:       r,
+		minSize: minSize,
+		avgSize: avgSize,
+		maxSize: maxSize,
+		maskS:   topBits(avgBits + 2),
+		maskL:   topBits(avgBits - 2),
+		buf:     make([]byte, 0, maxSize),
+	}
+}
+
+func topBits(n int) uint64 {
+	n = min(max(n, 1), 63)
+	return ^uint64(0) << (64 - n)
+}
+
+func (c *FastCDC) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if len(c.buf) == 0 {
+		return nil, io.EOF
+	}
+
+	n := c.cut(c.buf)
+	chunk := make([]byte, n)
+	copy(chunk, c.buf[:n])
+	c.buf = c.buf[:copy(c.buf, c.buf[n:])]
+	return chunk, nil
+}
+
+// fill reads until the buffer holds a maximum-size chunk or the stream ends.
+func (c *FastCDC) fill() error {
+	for !c.eof && len(c.buf) < c.maxSize {
+		n, err := c.r.Read(c.buf[len(c.buf):c.maxSize])
+		c.buf = c.buf[:len(c.buf)+n]
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cut returns the length of the chunk at the start of data.
+func (c *FastCDC) cut(data []byte) int {
+	n := len(data)
+	if n <= c.minSize {
+		return n
+	}
+
+	normal := min(c.avgSize, n)
+	var hash uint64
+	i := c.minSize
+	for ; i < normal; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		hash = (hash << 1) + gear[data[i]]
+		if hash&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// gear maps every byte to a random 64-bit value. The table is derived from
+// a fixed seed and must never change: chunk boundaries, and so
+// deduplication against stored chunks, depend on it.
+var gear = func() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x6a09e667f3bcc908)
+	for i := range table {
+		// splitmix64
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
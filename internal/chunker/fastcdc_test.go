@@ -0,0 +1,142 @@
+package chunker
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func splitAll(t *testing.T, c Chunker) [][]byte {
+	t.Helper()
+
+	var chunks [][]byte
+	for {
+		chunk, err := c.Next()
+		if errors.Is(err, io.EOF) {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestFastCDCBounds(t *testing.T) {
+	tests := []struct {
+		name                      string
+		data                      []byte
+		minSize, avgSize, maxSize int
+	}{
+		{name: "random", data: randomData(1, 1<<20), minSize: 2048, avgSize: 8192, maxSize: 32768},
+		{name: "small average", data: randomData(2, 1<<18), minSize: 64, avgSize: 256, maxSize: 1024},
+		{name: "zeros hit the maximum", data: make([]byte, 100000), minSize: 2048, avgSize: 8192, maxSize: 16384},
+		{name: "shorter than minimum", data: randomData(3, 1000), minSize: 2048, avgSize: 8192, maxSize: 32768},
+		{name: "min equals max", data: randomData(4, 50000), minSize: 4096, avgSize: 4096, maxSize: 4096},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitAll(t, NewFastCDC(bytes.NewReader(tt.data), tt.minSize, tt.avgSize, tt.maxSize))
+			for i, chunk := range chunks {
+				if len(chunk) > tt.maxSize {
+					t.Errorf("chunk %d is %d bytes, above the maximum %d", i, len(chunk), tt.maxSize)
+				}
+				if len(chunk) < tt.minSize && i != len(chunks)-1 {
+					t.Errorf("chunk %d is %d bytes, below the minimum %d", i, len(chunk), tt.minSize)
+				}
+				if len(chunk) == 0 {
+					t.Errorf("chunk %d is empty", i)
+				}
+			}
+		})
+	}
+}
+
+func TestFastCDCReassembly(t *testing.T) {
+	data := randomData(5, 300000)
+	reference := splitAll(t, NewFastCDC(bytes.NewReader(data), 1024, 4096, 16384))
+
+	tests := []struct {
+		name string
+		data []byte
+		wrap func(io.Reader) io.Reader
+	}{
+		{name: "whole reads", data: data, wrap: func(r io.Reader) io.Reader { return r }},
+		{name: "one byte reads", data: data, wrap: iotest.OneByteReader},
+		{name: "half reads", data: data, wrap: iotest.HalfReader},
+		{name: "empty", data: nil, wrap: func(r io.Reader) io.Reader { return r }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitAll(t, NewFastCDC(tt.wrap(bytes.NewReader(tt.data)), 1024, 4096, 16384))
+			if got := bytes.Join(chunks, nil); !bytes.Equal(got, tt.data) {
+				t.Fatalf("reassembled %d bytes, want %d bytes equal to the input", len(got), len(tt.data))
+			}
+
+			// The boundaries must not depend on how the reader splits the input.
+			if len(tt.data) > 0 {
+				for i := range reference {
+					if i >= len(chunks) || len(chunks[i]) != len(reference[i]) {
+						t.Fatalf("chunk %d differs from reading the input at once", i)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestFastCDCBoundaryStability checks that an edit only changes the chunks
+// around it, which is what makes content-defined chunking deduplicate.
+func TestFastCDCBoundaryStability(t *testing.T) {
+	original := randomData(6, 1<<20)
+
+	tests := []struct {
+		name string
+		edit func([]byte) []byte
+	}{
+		{name: "insert at start", edit: func(b []byte) []byte {
+			return append([]byte("inserted"), b...)
+		}},
+		{name: "insert in the middle", edit: func(b []byte) []byte {
+			return append(append(append([]byte{}, b[:len(b)/2]...), []byte("inserted")...), b[len(b)/2:]...)
+		}},
+		{name: "delete in the middle", edit: func(b []byte) []byte {
+			return append(append([]byte{}, b[:len(b)/2]...), b[len(b)/2+100:]...)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := splitAll(t, NewFastCDC(bytes.NewReader(original), 2048, 8192, 32768))
+			after := splitAll(t, NewFastCDC(bytes.NewReader(tt.edit(original)), 2048, 8192, 32768))
+
+			known := make(map[string]bool, len(before))
+			for _, chunk := range before {
+				known[string(chunk)] = true
+			}
+			changed := 0
+			for _, chunk := range after {
+				if !known[string(chunk)] {
+					changed++
+				}
+			}
+
+			// The edit touches one chunk; the boundary after it may shift
+			// into the next one before the cut points line up again.
+			if changed > 3 {
+				t.Errorf("%d of %d chunks changed after a single edit", changed, len(after))
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package chunker
+
+import "io"
+
+type Fixed struct {
+	r    io.Reader
+	size int
+	done bool
+}
+
+func NewFixed(r io.Reader, size int) *Fixed {
+	return &Fixed{r: r, size: max(size, 1)}
+}
+
+func (f *Fixed) Next() ([]byte, error) {
+	if f.done {
+		return nil, io.EOF
+	}
+
+	chunk := make([]byte, f.size)
+	n, err := io.ReadFull(f.r, chunk)
+	switch err {
+	case nil:
+		return chunk, nil
+	case io.EOF, io.ErrUnexpectedEOF:
+		f.done = true
+		if n == 0 {
+			return nil, io.EOF
+		}
+		return chunk[:n], nil
+	default:
+		return nil, err
+	}
+}
@@ -3,110 +3,340 @@ package clients
 import (
 	"context"
 	"errors"
+	"log"
+	"sort"
 	"sync"
 	"time"
 
 	filetransfer "s3-example/api/gen/go"
+	"s3-example/internal/placement"
 
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var ErrClientNotFound = errors.New("gRPC client not found")
+
+// NodeState is the liveness of a storage node as seen by its heartbeats.
+// Only healthy nodes receive new chunks; dead nodes are not read from.
+type NodeState string
+
+const (
+	NodeHealthy NodeState = "healthy"
+	NodeSuspect NodeState = "suspect"
+	NodeDead    NodeState = "dead"
 )
 
 type GrpcClientManager struct {
-	mu      sync.RWMutex
-	clients map[string]filetransfer.FileTransferServiceClient
+	mu       sync.RWMutex
+	nodes    map[string]*storageNode
+	registry Registry
+}
+
+// Registry persists registered nodes so that they can be reconnected after
+// a restart.
+type Registry interface {
+	SaveStorageNode(serviceName, grpcAddress string, weight float64) error
+	SetStorageNodeDraining(serviceName string, draining bool) error
+	DeleteStorageNode(serviceName string) error
+}
+
+type storageNode struct {
+	address  string
+	weight   float64
+	conn     *grpc.ClientConn
+	client   filetransfer.FileTransferServiceClient
+	health   healthpb.HealthClient
+	stats    NodeStats
+	state    NodeState
+	lastSeen time.Time
+	// draining nodes are being decommissioned: they keep serving reads but
+	// receive no new chunks.
+	draining bool
+}
+
+// NodeStats is the disk usage last reported by a storage node.
+type NodeStats struct {
+	FreeBytes  uint64    `json:"free_bytes"`
+	UsedBytes  uint64    `json:"used_bytes"`
+	TotalBytes uint64    `json:"total_bytes"`
+	ChunkCount int64     `json:"chunk_count"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
-func NewGrpcClientManager() *GrpcClientManager {
+// NodeInfo describes a registered storage node.
+type NodeInfo struct {
+	ServiceName string    `json:"service_name"`
+	Address     string    `json:"address"`
+	Weight      float64   `json:"weight"`
+	State       NodeState `json:"state"`
+	Draining    bool      `json:"draining"`
+	LastSeen    time.Time `json:"last_seen"`
+	Stats       NodeStats `json:"stats"`
+}
+
+func NewGrpcClientManager(registry Registry) *GrpcClientManager {
 	return &GrpcClientManager{
-		clients: make(map[string]filetransfer.FileTransferServiceClient),
+		nodes:    make(map[string]*storageNode),
+		registry: registry,
+	}
+}
+
+// RegisterClient connects to a storage node. The weight sets the node's
+// share of chunk placements relative to other nodes; non-positive weights
+// count as 1. Registering a known node again updates its weight and marks
+// it healthy; if its address changed, the node keeps its name, and so its
+// chunks, but is reached through a new connection.
+func (m *GrpcClientManager) RegisterClient(serviceName, address string, weight float64) error {
+	if weight <= 0 {
+		weight = 1
+	}
+
+	m.mu.Lock()
+	node, exists := m.nodes[serviceName]
+	if exists && node.address == address {
+		defer m.mu.Unlock()
+		return m.reregister(serviceName, node, weight)
+	}
+	m.mu.Unlock()
+
+	// Dialing blocks for up to five seconds, so it must not hold m.mu.
+	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The node may have registered through another request meanwhile.
+	node, exists = m.nodes[serviceName]
+	if exists && node.address == address {
+		if err := conn.Close(); err != nil {
+			log.Printf("Error closing connection to %s: %v", address, err)
+		}
+		return m.reregister(serviceName, node, weight)
+	}
+
+	draining := false
+	if exists {
+		log.Printf("Storage node %s moved from %s to %s", serviceName, node.address, address)
+		if err := node.conn.Close(); err != nil {
+			log.Printf("Error closing connection to %s: %v", node.address, err)
+		}
+		draining = node.draining
 	}
+
+	node = newStorageNode(address, weight, conn)
+	node.draining = draining
+	m.nodes[serviceName] = node
+
+	go m.refreshNodeStats(context.Background(), serviceName, node)
+
+	return m.persist(serviceName, node)
 }
 
-func (m *GrpcClientManager) RegisterClient(serviceName, address string) error {
+// reregister updates a known node that registered again at the same
+// address. Callers must hold m.mu.
+func (m *GrpcClientManager) reregister(serviceName string, node *storageNode, weight float64) error {
+	node.weight = weight
+	node.state = NodeHealthy
+	node.lastSeen = time.Now()
+	return m.persist(serviceName, node)
+}
+
+// RestoreClient adds a node persisted by an earlier run without waiting for
+// the connection. The node stays suspect until its first heartbeat succeeds.
+func (m *GrpcClientManager) RestoreClient(serviceName, address string, weight float64, draining bool) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.clients[serviceName]; exists {
-		return errors.New("gRPC client already registered")
+	if _, exists := m.nodes[serviceName]; exists {
+		return nil
 	}
 
-	conn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
 	if err != nil {
 		return err
 	}
 
-	client := filetransfer.NewFileTransferServiceClient(conn)
-	m.clients[serviceName] = client
+	node := newStorageNode(address, weight, conn)
+	node.state = NodeSuspect
+	node.draining = draining
+	m.nodes[serviceName] = node
 
 	return nil
 }
 
-func (m *GrpcClientManager) GetClients() []filetransfer.FileTransferServiceClient {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+func newStorageNode(address string, weight float64, conn *grpc.ClientConn) *storageNode {
+	return &storageNode{
+		address:  address,
+		weight:   weight,
+		conn:     conn,
+		client:   filetransfer.NewFileTransferServiceClient(conn),
+		health:   healthpb.NewHealthClient(conn),
+		state:    NodeHealthy,
+		lastSeen: time.Now(),
+	}
+}
+
+// persist records the node in the registry. Callers must hold m.mu so that
+// the registry sees registrations in the same order as the node map.
+func (m *GrpcClientManager) persist(serviceName string, node *storageNode) error {
+	if m.registry == nil {
+		return nil
+	}
+	return m.registry.SaveStorageNode(serviceName, node.address, node.weight)
+}
+
+func (m *GrpcClientManager) forget(serviceName string) {
+	if m.registry == nil {
+		return
+	}
+	if err := m.registry.DeleteStorageNode(serviceName); err != nil {
+		log.Printf("Error removing %s from the node registry: %v", serviceName, err)
+	}
+}
+
+// SetDraining starts or stops decommissioning a node.
+func (m *GrpcClientManager) SetDraining(serviceName string, draining bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	clients := make([]filetransfer.FileTransferServiceClient, 0, len(m.clients))
-	for _, client := range m.clients {
-		clients = append(clients, client)
+	node, exists := m.nodes[serviceName]
+	if !exists {
+		return ErrClientNotFound
 	}
 
-	return clients
+	if m.registry != nil {
+		if err := m.registry.SetStorageNodeDraining(serviceName, draining); err != nil {
+			return err
+		}
+	}
+	node.draining = draining
+	return nil
+}
+
+// DeregisterClient forgets a storage node and closes its connection.
+func (m *GrpcClientManager) DeregisterClient(serviceName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, exists := m.nodes[serviceName]
+	if !exists {
+		return ErrClientNotFound
+	}
+
+	delete(m.nodes, serviceName)
+	m.forget(serviceName)
+	return node.conn.Close()
 }
 
 func (m *GrpcClientManager) GetClientNames() []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	names := make([]string, 0, len(m.clients))
-	for name := range m.clients {
+	names := make([]string, 0, len(m.nodes))
+	for name := range m.nodes {
 		names = append(names, name)
 	}
 
 	return names
 }
 
+// GetNodes returns the healthy nodes that may receive new chunks. Draining
+// nodes are left out.
+func (m *GrpcClientManager) GetNodes() []placement.Node {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	nodes := make([]placement.Node, 0, len(m.nodes))
+	for name, node := range m.nodes {
+		if node.state != NodeHealthy || node.draining {
+			continue
+		}
+		nodes = append(nodes, placement.Node{
+			Name:       name,
+			Weight:     node.weight,
+			FreeBytes:  node.stats.FreeBytes,
+			TotalBytes: node.stats.TotalBytes,
+		})
+	}
+
+	return nodes
+}
+
+// GetClientsByName returns the clients of all nodes that are not dead.
 func (m *GrpcClientManager) GetClientsByName() map[string]filetransfer.FileTransferServiceClient {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	clientsCopy := make(map[string]filetransfer.FileTransferServiceClient)
-	for name, client := range m.clients {
-		clientsCopy[name] = client
+	for name, node := range m.nodes {
+		if node.state != NodeDead {
+			clientsCopy[name] = node.client
+		}
 	}
 
 	return clientsCopy
 }
 
+// GetClientByName returns nil if the node is unknown or dead.
 func (m *GrpcClientManager) GetClientByName(name string) filetransfer.FileTransferServiceClient {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	return m.clients[name]
+	node, ok := m.nodes[name]
+	if !ok || node.state == NodeDead {
+		return nil
+	}
+	return node.client
 }
 
-func (m *GrpcClientManager) SendChunks(client filetransfer.FileTransferServiceClient, chunks []*filetransfer.FileChunk) error {
-	ctx := context.Background()
-	stream, err := client.TransferFile(ctx)
-	if err != nil {
-		return err
+func (m *GrpcClientManager) GetNode(name string) (NodeInfo, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, ok := m.nodes[name]
+	if !ok {
+		return NodeInfo{}, false
 	}
+	return node.info(name), true
+}
 
-	for _, chunk := range chunks {
-		if err := stream.Send(chunk); err != nil {
-			return err
-		}
+func (m *GrpcClientManager) ListNodes() []NodeInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	nodes := make([]NodeInfo, 0, len(m.nodes))
+	for name, node := range m.nodes {
+		nodes = append(nodes, node.info(name))
 	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ServiceName < nodes[j].ServiceName
+	})
 
-	_, err = stream.CloseAndRecv()
-	if err != nil {
-		return err
+	return nodes
+}
+
+func (node *storageNode) info(name string) NodeInfo {
+	return NodeInfo{
+		ServiceName: name,
+		Address:     node.address,
+		Weight:      node.weight,
+		State:       node.state,
+		Draining:    node.draining,
+		LastSeen:    node.lastSeen,
+		Stats:       node.stats,
 	}
+}
 
-	return nil
+func (m *GrpcClientManager) OpenTransferStream(ctx context.Context, client filetransfer.FileTransferServiceClient) (filetransfer.FileTransferService_TransferFileClient, error) {
+	return client.TransferFile(ctx)
 }
 
-func (m *GrpcClientManager) GetChunk(client filetransfer.FileTransferServiceClient, filename string, chunkNumber int32, chunkHash string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (m *GrpcClientManager) GetChunk(ctx context.Context, client filetransfer.FileTransferServiceClient, filename string, chunkNumber int32, chunkHash string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	request := &filetransfer.ChunkRequest{
@@ -122,3 +352,176 @@ func (m *GrpcClientManager) GetChunk(client filetransfer.FileTransferServiceClie
 
 	return response.Chunk, nil
 }
+
+// PutChunk stores a single chunk on a node over its own transfer stream.
+func (m *GrpcClientManager) PutChunk(ctx context.Context, client filetransfer.FileTransferServiceClient, chunk *filetransfer.FileChunk) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	stream, err := client.TransferFile(ctx)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(chunk); err != nil {
+		return err
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+func (m *GrpcClientManager) DeleteChunk(ctx context.Context, client filetransfer.FileTransferServiceClient, chunkHash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	response, err := client.DeleteChunk(ctx, &filetransfer.DeleteChunkRequest{ChunkHash: chunkHash})
+	if err != nil {
+		return false, err
+	}
+
+	return response.Deleted, nil
+}
+
+// RunHeartbeats checks every node each interval. A node that misses a
+// heartbeat becomes suspect, one that has not answered for deadAfter is
+// dead, and one that has not answered for deregisterAfter is deregistered
+// (never, if deregisterAfter is zero). The first check runs immediately so
+// that restored nodes become usable without waiting a full interval. A zero
+// interval runs only that first check.
+func (m *GrpcClientManager) RunHeartbeats(ctx context.Context, interval, deadAfter, deregisterAfter time.Duration) {
+	timeout := 5 * time.Second
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+		timeout = min(interval, timeout)
+	}
+
+	for {
+		m.heartbeat(ctx, timeout, deadAfter, deregisterAfter)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		}
+	}
+}
+
+func (m *GrpcClientManager) heartbeat(ctx context.Context, timeout, deadAfter, deregisterAfter time.Duration) {
+	m.mu.RLock()
+	nodes := make(map[string]*storageNode, len(m.nodes))
+	for name, node := range m.nodes {
+		nodes[name] = node
+	}
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for name, node := range nodes {
+		wg.Add(1)
+		go func(name string, node *storageNode) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			response, err := node.health.Check(checkCtx, &healthpb.HealthCheckRequest{})
+			if err == nil && response.Status != healthpb.HealthCheckResponse_SERVING {
+				err = errors.New("node is not serving")
+			}
+			m.updateState(name, node, err, deadAfter, deregisterAfter)
+		}(name, node)
+	}
+	wg.Wait()
+}
+
+func (m *GrpcClientManager) updateState(name string, node *storageNode, err error, deadAfter, deregisterAfter time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nodes[name] != node {
+		return
+	}
+
+	if err == nil {
+		if node.state != NodeHealthy {
+			log.Printf("Storage node %s is healthy again", name)
+		}
+		node.state = NodeHealthy
+		node.lastSeen = time.Now()
+		return
+	}
+
+	silent := time.Since(node.lastSeen)
+	switch {
+	case deregisterAfter > 0 && silent >= deregisterAfter:
+		log.Printf("Deregistering storage node %s: no heartbeat for %s", name, silent.Round(time.Second))
+		delete(m.nodes, name)
+		m.forget(name)
+		node.conn.Close()
+	case silent >= deadAfter:
+		if node.state != NodeDead {
+			log.Printf("Storage node %s is dead: %v", name, err)
+		}
+		node.state = NodeDead
+	default:
+		if node.state == NodeHealthy {
+			log.Printf("Storage node %s is suspect: %v", name, err)
+			node.state = NodeSuspect
+		}
+	}
+}
+
+// PollNodeStats refreshes the disk usage of every registered node each
+// interval until ctx is cancelled. A zero interval disables polling, so
+// nodes are placed as if they had the average free space.
+func (m *GrpcClientManager) PollNodeStats(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.mu.RLock()
+			nodes := make(map[string]*storageNode, len(m.nodes))
+			for name, node := range m.nodes {
+				if node.state != NodeDead {
+					nodes[name] = node
+				}
+			}
+			m.mu.RUnlock()
+
+			for name, node := range nodes {
+				m.refreshNodeStats(ctx, name, node)
+			}
+		}
+	}
+}
+
+func (m *GrpcClientManager) refreshNodeStats(ctx context.Context, serviceName string, node *storageNode) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	response, err := node.client.NodeStats(ctx, &filetransfer.NodeStatsRequest{})
+	if err != nil {
+		log.Printf("Error getting stats of %s: %v", serviceName, err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node.stats = NodeStats{
+		FreeBytes:  response.FreeBytes,
+		UsedBytes:  response.UsedBytes,
+		TotalBytes: response.TotalBytes,
+		ChunkCount: response.ChunkCount,
+		UpdatedAt:  time.Now(),
+	}
+}
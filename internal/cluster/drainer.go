@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"s3-example/internal/clients"
+	"s3-example/internal/storage"
+)
+
+const drainBatchSize = 100
+
+// Drainer decommissions draining nodes. Every chunk on such a node is first
+// copied to other nodes until it has its full redundancy without the node,
+// and only then is the node's replica dropped, so reads keep working
+// throughout. Once no replica is left on the node it is deregistered.
+type Drainer struct {
+	dbManager         *storage.Manager
+	grpcClientManager *clients.GrpcClientManager
+	repairer          *Repairer
+	interval          time.Duration
+	trigger           chan struct{}
+}
+
+func NewDrainer(dbManager *storage.Manager, grpcClientManager *clients.GrpcClientManager, repairer *Repairer, interval time.Duration) *Drainer {
+	return &Drainer{
+		dbManager:         dbManager,
+		grpcClientManager: grpcClientManager,
+		repairer:          repairer,
+		interval:          interval,
+		trigger:           make(chan struct{}, 1),
+	}
+}
+
+// Run drains every interval, or whenever Trigger is called. A zero interval
+// disables periodic draining.
+func (d *Drainer) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if d.interval > 0 {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-d.trigger:
+		}
+
+		for _, node := range d.grpcClientManager.ListNodes() {
+			if !node.Draining || node.State == clients.NodeDead {
+				continue
+			}
+			if err := d.drain(ctx, node.ServiceName); err != nil {
+				log.Printf("Error draining %s: %v", node.ServiceName, err)
+			}
+		}
+	}
+}
+
+// Trigger starts draining as soon as the current pass, if any, ends.
+func (d *Drainer) Trigger() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Drainer) drain(ctx context.Context, serviceName string) error {
+	moved, failed := 0, 0
+	afterID := int64(0)
+	for {
+		chunks, err := d.dbManager.ListNodeChunks(serviceName, afterID, drainBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			break
+		}
+
+		for _, chunk := range chunks {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			afterID = chunk.ID
+
+			if _, err := d.repairer.repairChunk(ctx, chunk); err != nil {
+				log.Printf("Error moving chunk %s off %s: %v", chunk.ChunkHash, serviceName, err)
+				failed++
+				continue
+			}
+			if err := d.dbManager.RemoveChunkReplica(chunk.ID, serviceName); err != nil {
+				return err
+			}
+			moved++
+		}
+	}
+
+	remaining, err := d.dbManager.CountNodeReplicas(serviceName)
+	if err != nil {
+		return err
+	}
+	log.Printf("Drained %d chunks off %s, %d failed, %d remaining", moved, serviceName, failed, remaining)
+	if remaining > 0 {
+		return nil
+	}
+
+	// Check the flag again so that a drain cancelled in the meantime does
+	// not end in deregistration.
+	node, ok := d.grpcClientManager.GetNode(serviceName)
+	if !ok || !node.Draining {
+		return nil
+	}
+
+	log.Printf("Storage node %s holds no chunks anymore, deregistering", serviceName)
+	err = d.grpcClientManager.DeregisterClient(serviceName)
+	if err == clients.ErrClientNotFound {
+		return nil
+	}
+	return err
+}
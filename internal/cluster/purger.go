@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"s3-example/internal/clients"
+	"s3-example/internal/storage"
+)
+
+const purgeBatchSize = 100
+
+// Purger removes chunk files that are no longer referenced by any object.
+// Deleting metadata queues the affected chunks in the database; the purger
+// drains that queue in the background, so deletes never wait for the
+// storage nodes. Entries for nodes that are not connected stay queued until
+// the node comes back. The purger also aborts resumable uploads whose session
+// has expired.
+type Purger struct {
+	dbManager         *storage.Manager
+	grpcClientManager *clients.GrpcClientManager
+	interval          time.Duration
+}
+
+func NewPurger(dbManager *storage.Manager, grpcClientManager *clients.GrpcClientManager, interval time.Duration) *Purger {
+	return &Purger{
+		dbManager:         dbManager,
+		grpcClientManager: grpcClientManager,
+		interval:          interval,
+	}
+}
+
+// Run purges every interval until ctx is cancelled. A zero interval
+// disables purging, so queued chunk files stay on the nodes.
+func (p *Purger) Run(ctx context.Context) {
+	if p.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.abortExpiredUploads()
+			if err := p.purge(ctx); err != nil {
+				log.Printf("Error purging chunks: %v", err)
+			}
+		}
+	}
+}
+
+// abortExpiredUploads aborts resumable uploads whose session has expired,
+// so their chunks are queued and purged in the same pass.
+func (p *Purger) abortExpiredUploads() {
+	aborted, err := p.dbManager.AbortExpiredUploads()
+	if err != nil {
+		log.Printf("Error aborting expired uploads: %v", err)
+		return
+	}
+	if aborted > 0 {
+		log.Printf("Aborted %d expired resumable uploads", aborted)
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) error {
+	afterID := int64(0)
+	for {
+		entries, err := p.dbManager.ListPurgeQueue(afterID, purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		for _, entry := range entries {
+			afterID = entry.ID
+			if err := p.purgeEntry(ctx, entry); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// purgeEntry deletes the chunk file under a claim on its queue entry, so an
+// upload cannot reference the file between the check and the delete.
+func (p *Purger) purgeEntry(ctx context.Context, entry storage.PurgeEntry) error {
+	claim, err := p.dbManager.ClaimPurge(entry)
+	if err != nil {
+		return err
+	}
+	if claim == nil {
+		return nil
+	}
+
+	if !claim.Referenced {
+		client := p.grpcClientManager.GetClientByName(entry.ServiceName)
+		if client == nil {
+			return claim.Release()
+		}
+		if _, err := p.grpcClientManager.DeleteChunk(ctx, client, entry.ChunkHash); err != nil {
+			log.Printf("Error deleting chunk %s from %s: %v", entry.ChunkHash, entry.ServiceName, err)
+			return claim.Release()
+		}
+	}
+
+	return claim.Finish()
+}
@@ -0,0 +1,414 @@
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	filetransfer "s3-example/api/gen/go"
+	"s3-example/internal/clients"
+	"s3-example/internal/placement"
+	"s3-example/internal/storage"
+)
+
+const rebalanceScanSize = 500
+
+// RebalanceStatus describes the current or last rebalancing pass.
+type RebalanceStatus struct {
+	Running       bool      `json:"running"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	ChunksScanned int64     `json:"chunks_scanned"`
+	Misplaced     int64     `json:"misplaced"`
+	Moved         int64     `json:"moved"`
+	Failed        int64     `json:"failed"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// Rebalancer moves existing chunks to the nodes the placement policy would
+// choose for them today, so that nodes added after an upload take their
+// share of its chunks. A copy is written to the new node and read back
+// before the chunk row is switched over to it; the old copy is purged only
+// after that. Moves happen in batches of batchSize with a pause in between
+// to limit the load on the cluster. Chunks with a replica on a dead or
+// draining node are left to the repairer and the drainer.
+//
+// Rebalancing only converges for the consistent-hash policy, whose choice
+// depends on nothing but the node set. The capacity policy scores nodes by
+// their free space, which every move changes, so with it chunks are only
+// moved off nodes over the high-water mark.
+type Rebalancer struct {
+	dbManager         *storage.Manager
+	grpcClientManager *clients.GrpcClientManager
+	placement         placement.Placement
+	readChunk         ChunkReader
+	replicationFactor int
+	interval          time.Duration
+	batchSize         int
+	pause             time.Duration
+	trigger           chan struct{}
+
+	mu     sync.Mutex
+	status RebalanceStatus
+}
+
+type chunkMove struct {
+	from string
+	to   string
+}
+
+func NewRebalancer(dbManager *storage.Manager, grpcClientManager *clients.GrpcClientManager, chunkPlacement placement.Placement, readChunk ChunkReader, replicationFactor int, interval time.Duration, batchSize int, pause time.Duration) *Rebalancer {
+	return &Rebalancer{
+		dbManager:         dbManager,
+		grpcClientManager: grpcClientManager,
+		placement:         chunkPlacement,
+		readChunk:         readChunk,
+		replicationFactor: max(replicationFactor, 1),
+		interval:          interval,
+		batchSize:         max(batchSize, 1),
+		pause:             pause,
+		trigger:           make(chan struct{}, 1),
+	}
+}
+
+// Run rebalances every interval, or whenever Trigger is called. A zero
+// interval disables periodic rebalancing.
+func (r *Rebalancer) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if r.interval > 0 {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-r.trigger:
+		}
+
+		r.rebalance(ctx)
+	}
+}
+
+// Trigger starts a rebalancing pass as soon as the current one, if any,
+// ends.
+func (r *Rebalancer) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Rebalancer) Status() RebalanceStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.status
+}
+
+func (r *Rebalancer) update(f func(status *RebalanceStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f(&r.status)
+}
+
+func (r *Rebalancer) rebalance(ctx context.Context) {
+	r.update(func(status *RebalanceStatus) {
+		*status = RebalanceStatus{Running: true, StartedAt: time.Now()}
+	})
+
+	err := r.rebalanceAll(ctx)
+
+	r.update(func(status *RebalanceStatus) {
+		status.Running = false
+		status.FinishedAt = time.Now()
+		if err != nil {
+			status.LastError = err.Error()
+		}
+	})
+
+	status := r.Status()
+	if err != nil {
+		log.Printf("Error rebalancing chunks: %v", err)
+	}
+	if status.Misplaced > 0 {
+		log.Printf("Rebalance finished: %d chunks scanned, %d misplaced, %d moved, %d failed",
+			status.ChunksScanned, status.Misplaced, status.Moved, status.Failed)
+	}
+}
+
+func (r *Rebalancer) rebalanceAll(ctx context.Context) error {
+	nodes := r.grpcClientManager.GetNodes()
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	moved := 0
+	afterID := int64(0)
+	for {
+		chunks, err := r.dbManager.ListChunks(afterID, rebalanceScanSize)
+		if err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+
+		for _, chunk := range chunks {
+			afterID = chunk.ID
+
+			moves, err := r.planChunk(chunk, nodes)
+			r.update(func(status *RebalanceStatus) {
+				status.ChunksScanned++
+				if len(moves) > 0 {
+					status.Misplaced++
+				}
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, move := range moves {
+				if moved > 0 && moved%r.batchSize == 0 {
+					if err := sleepContext(ctx, r.pause); err != nil {
+						return err
+					}
+					// Nodes may have joined, left or filled up meanwhile.
+					nodes = r.grpcClientManager.GetNodes()
+				}
+				moved++
+
+				err := r.move(ctx, chunk, move)
+				r.update(func(status *RebalanceStatus) {
+					if err != nil {
+						status.Failed++
+						status.LastError = err.Error()
+					} else {
+						status.Moved++
+					}
+				})
+				if err != nil {
+					log.Printf("Error moving chunk %s from %s to %s: %v", chunk.ChunkHash, move.from, move.to, err)
+					break
+				}
+			}
+		}
+	}
+}
+
+// planChunk skips chunks with a replica on a dead or draining node and
+// loads the stripe of an erasure-coded chunk before planning its moves.
+func (r *Rebalancer) planChunk(chunk storage.ChunkMetadata, nodes []placement.Node) ([]chunkMove, error) {
+	if len(chunk.Replicas) == 0 {
+		return nil, nil
+	}
+	for _, serviceName := range chunk.Replicas {
+		if !isLive(r.grpcClientManager, serviceName) {
+			return nil, nil
+		}
+	}
+
+	var members []storage.ChunkMetadata
+	if chunk.StripeNumber != storage.NoStripe {
+		var err error
+		members, err = r.dbManager.GetStripeChunkMetadata(chunk)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.plan(chunk, members, nodes), nil
+}
+
+// plan returns the moves that bring the chunk's replicas onto the nodes the
+// placement policy chooses for it. members is the stripe of an
+// erasure-coded chunk.
+func (r *Rebalancer) plan(chunk storage.ChunkMetadata, members []storage.ChunkMetadata, nodes []placement.Node) []chunkMove {
+	if policy, ok := r.placement.(placement.FillAware); ok {
+		return r.planOverfull(chunk, members, nodes, policy)
+	}
+	if chunk.StripeNumber != storage.NoStripe {
+		return r.planShard(chunk, members, nodes)
+	}
+
+	desired, err := r.placement.Place(chunk.ChunkHash, nodes, min(r.replicationFactor, len(nodes)))
+	if err != nil {
+		return nil
+	}
+
+	current := make(map[string]bool, len(chunk.Replicas))
+	for _, serviceName := range chunk.Replicas {
+		current[serviceName] = true
+	}
+	wanted := make(map[string]bool, len(desired))
+	var missing []string
+	for _, serviceName := range desired {
+		wanted[serviceName] = true
+		if !current[serviceName] {
+			missing = append(missing, serviceName)
+		}
+	}
+
+	var moves []chunkMove
+	for _, serviceName := range chunk.Replicas {
+		if len(missing) == 0 {
+			break
+		}
+		if !wanted[serviceName] {
+			moves = append(moves, chunkMove{from: serviceName, to: missing[0]})
+			missing = missing[1:]
+		}
+	}
+	return moves
+}
+
+// planShard places an erasure-coded chunk the way uploads do: the whole
+// stripe by the hash of its first shard, one node per shard index. A shard
+// is only moved to a node that holds no other shard of the stripe yet.
+func (r *Rebalancer) planShard(chunk storage.ChunkMetadata, members []storage.ChunkMetadata, nodes []placement.Node) []chunkMove {
+	if len(chunk.Replicas) != 1 {
+		return nil
+	}
+
+	firstHash := ""
+	occupied := make(map[string]bool)
+	for _, member := range members {
+		if member.ShardIndex == 0 {
+			firstHash = member.ChunkHash
+		}
+		for _, serviceName := range member.Replicas {
+			occupied[serviceName] = true
+		}
+	}
+	if firstHash == "" || int(chunk.ShardIndex) >= len(members) {
+		return nil
+	}
+
+	desired, err := r.placement.Place(firstHash, nodes, len(members))
+	if err != nil {
+		return nil
+	}
+
+	target := desired[chunk.ShardIndex]
+	if target == chunk.Replicas[0] || occupied[target] {
+		return nil
+	}
+	return []chunkMove{{from: chunk.Replicas[0], to: target}}
+}
+
+// planOverfull moves the replicas on overfull nodes to the nodes the policy
+// prefers among those that hold no replica of the chunk, or no shard of its
+// stripe, yet. Replicas elsewhere stay where they are.
+func (r *Rebalancer) planOverfull(chunk storage.ChunkMetadata, members []storage.ChunkMetadata, nodes []placement.Node, policy placement.FillAware) []chunkMove {
+	byName := make(map[string]placement.Node, len(nodes))
+	for _, node := range nodes {
+		byName[node.Name] = node
+	}
+	var overfull []string
+	for _, serviceName := range chunk.Replicas {
+		if node, ok := byName[serviceName]; ok && policy.Overfull(node) {
+			overfull = append(overfull, serviceName)
+		}
+	}
+	if len(overfull) == 0 {
+		return nil
+	}
+
+	occupied := make(map[string]bool)
+	for _, serviceName := range chunk.Replicas {
+		occupied[serviceName] = true
+	}
+	for _, member := range members {
+		for _, serviceName := range member.Replicas {
+			occupied[serviceName] = true
+		}
+	}
+
+	var candidates []placement.Node
+	for _, node := range nodes {
+		if !occupied[node.Name] {
+			candidates = append(candidates, node)
+		}
+	}
+	targets, err := r.placement.Place(chunk.ChunkHash, candidates, len(overfull))
+	if err != nil {
+		return nil
+	}
+
+	moves := make([]chunkMove, len(overfull))
+	for i, serviceName := range overfull {
+		moves[i] = chunkMove{from: serviceName, to: targets[i]}
+	}
+	return moves
+}
+
+// move records the new replica, copies the chunk to its node, reads the
+// copy back and only then switches the replica over. Recording it first
+// keeps the chunk file referenced while it is written, so a pending purge
+// of the same file cannot delete it. If the copy fails, the new replica is
+// dropped again.
+func (r *Rebalancer) move(ctx context.Context, chunk storage.ChunkMetadata, move chunkMove) error {
+	client := r.grpcClientManager.GetClientByName(move.to)
+	if client == nil {
+		return fmt.Errorf("gRPC client not found for service: %s", move.to)
+	}
+
+	data, err := r.readChunk(ctx, chunk)
+	if err != nil {
+		return err
+	}
+
+	if err := r.dbManager.AddChunkReplica(chunk.ID, move.to, chunk.StoredHash); err != nil {
+		return err
+	}
+	if err := r.copy(ctx, client, chunk, data); err != nil {
+		if err := r.dbManager.RemoveChunkReplica(chunk.ID, move.to); err != nil {
+			log.Printf("Error dropping replica of chunk %s on %s: %v", chunk.ChunkHash, move.to, err)
+		}
+		return fmt.Errorf("%v on %s", err, move.to)
+	}
+
+	return r.dbManager.MoveChunkReplica(chunk.ID, move.from, move.to, chunk.StoredHash)
+}
+
+// copy writes the chunk to the node and verifies it by reading it back.
+func (r *Rebalancer) copy(ctx context.Context, client filetransfer.FileTransferServiceClient, chunk storage.ChunkMetadata, data []byte) error {
+	err := r.grpcClientManager.PutChunk(ctx, client, &filetransfer.FileChunk{
+		Chunk:       data,
+		ChunkNumber: chunk.ChunkNumber,
+		ChunkHash:   chunk.StoredHash,
+	})
+	if err != nil {
+		return fmt.Errorf("Error sending chunk: %v", err)
+	}
+
+	stored, err := r.grpcClientManager.GetChunk(ctx, client, "", chunk.ChunkNumber, chunk.StoredHash)
+	if err != nil {
+		return fmt.Errorf("Error reading chunk back: %v", err)
+	}
+	hash := sha256.Sum256(stored)
+	if hex.EncodeToString(hash[:]) != chunk.StoredHash {
+		return errors.New("Chunk hash mismatch after copying")
+	}
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,223 @@
+package cluster
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"s3-example/internal/placement"
+	"s3-example/internal/storage"
+)
+
+func testNodes(names ...string) []placement.Node {
+	nodes := make([]placement.Node, len(names))
+	for i, name := range names {
+		nodes[i] = placement.Node{Name: name, Weight: 1}
+	}
+	return nodes
+}
+
+// applyMoves returns the replicas after the moves, checking that each one
+// takes a replica off a node that has it onto a node that has none.
+func applyMoves(t *testing.T, replicas []string, moves []chunkMove) []string {
+	t.Helper()
+
+	result := slices.Clone(replicas)
+	for _, move := range moves {
+		i := slices.Index(result, move.from)
+		if i < 0 {
+			t.Fatalf("move %v takes a replica off a node that has none: %v", move, result)
+		}
+		if slices.Contains(result, move.to) {
+			t.Fatalf("move %v puts a second replica on a node: %v", move, result)
+		}
+		result[i] = move.to
+	}
+	return result
+}
+
+func TestRebalancerPlan(t *testing.T) {
+	ring := placement.NewRing(placement.DefaultVirtualNodes)
+	placed := func(nodes []placement.Node) func(key string) []string {
+		return func(key string) []string {
+			replicas, err := ring.Place(key, nodes, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return replicas
+		}
+	}
+
+	tests := []struct {
+		name     string
+		replicas func(key string) []string
+		nodes    []placement.Node
+		// onlyTo, if set, is the only node chunks may move to.
+		onlyTo    string
+		wantMoves bool
+	}{
+		{name: "balanced", replicas: placed(testNodes("a", "b", "c", "d")), nodes: testNodes("a", "b", "c", "d")},
+		{name: "node added", replicas: placed(testNodes("a", "b", "c")), nodes: testNodes("a", "b", "c", "d"), onlyTo: "d", wantMoves: true},
+		{
+			name:      "skewed onto two nodes",
+			replicas:  func(string) []string { return []string{"a", "b"} },
+			nodes:     testNodes("a", "b", "c", "d", "e"),
+			wantMoves: true,
+		},
+		{
+			name:      "one replica short",
+			replicas:  func(string) []string { return []string{"a"} },
+			nodes:     testNodes("a", "b", "c"),
+			wantMoves: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rebalancer{placement: ring, replicationFactor: 2}
+
+			moved := 0
+			for i := 0; i < 200; i++ {
+				chunk := storage.ChunkMetadata{ChunkHash: fmt.Sprintf("chunk-%d", i), StripeNumber: storage.NoStripe}
+				chunk.Replicas = tt.replicas(chunk.ChunkHash)
+
+				moves := r.plan(chunk, nil, tt.nodes)
+				if len(moves) > 0 {
+					moved++
+				}
+				for _, move := range moves {
+					if tt.onlyTo != "" && move.to != tt.onlyTo {
+						t.Fatalf("plan(%s) moves to %s, want only moves to %s", chunk.ChunkHash, move.to, tt.onlyTo)
+					}
+				}
+
+				// Planning again after the moves must find nothing to do.
+				chunk.Replicas = applyMoves(t, chunk.Replicas, moves)
+				if again := r.plan(chunk, nil, tt.nodes); len(again) > 0 {
+					t.Fatalf("plan(%s) = %v after moving to %v, want no moves", chunk.ChunkHash, again, chunk.Replicas)
+				}
+			}
+
+			if tt.wantMoves != (moved > 0) {
+				t.Errorf("%d of 200 chunks moved, want moves: %v", moved, tt.wantMoves)
+			}
+		})
+	}
+}
+
+func TestRebalancerPlanShard(t *testing.T) {
+	ring := placement.NewRing(placement.DefaultVirtualNodes)
+	nodes := testNodes("a", "b", "c", "d", "e", "f", "g", "h")
+
+	// stripe returns the 4+2 shards of a stripe, each on the node given for
+	// its shard index.
+	stripe := func(replicas []string) []storage.ChunkMetadata {
+		members := make([]storage.ChunkMetadata, len(replicas))
+		for i, serviceName := range replicas {
+			members[i] = storage.ChunkMetadata{
+				ChunkHash:    fmt.Sprintf("shard-%d", i),
+				Replicas:     []string{serviceName},
+				StripeNumber: 0,
+				ShardIndex:   int32(i),
+				IsParity:     i >= 4,
+			}
+		}
+		return members
+	}
+	desired, err := ring.Place("shard-0", nodes, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		replicas  []string
+		wantMoves bool
+	}{
+		{name: "balanced", replicas: desired},
+		{name: "skewed", replicas: []string{"a", "b", "c", "d", "e", "f"}, wantMoves: true},
+		{name: "two shards swapped", replicas: append([]string{desired[1], desired[0]}, desired[2:]...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rebalancer{placement: ring, replicationFactor: 1}
+			members := stripe(tt.replicas)
+
+			moved := 0
+			for _, chunk := range members {
+				for _, move := range r.plan(chunk, members, nodes) {
+					moved++
+					if move.to != desired[chunk.ShardIndex] {
+						t.Errorf("shard %d moves to %s, want %s", chunk.ShardIndex, move.to, desired[chunk.ShardIndex])
+					}
+					if slices.Contains(tt.replicas, move.to) {
+						t.Errorf("shard %d moves to %s, which holds another shard of the stripe", chunk.ShardIndex, move.to)
+					}
+				}
+			}
+
+			if tt.wantMoves != (moved > 0) {
+				t.Errorf("%d shards moved, want moves: %v", moved, tt.wantMoves)
+			}
+		})
+	}
+}
+
+func TestRebalancerPlanOverfull(t *testing.T) {
+	node := func(name string, usedPercent uint64) placement.Node {
+		return placement.Node{Name: name, Weight: 1, FreeBytes: 100 - usedPercent, TotalBytes: 100}
+	}
+	balanced := []placement.Node{node("a", 50), node("b", 60), node("c", 40), node("d", 50)}
+	skewed := []placement.Node{node("a", 95), node("b", 60), node("c", 10), node("d", 20), node("e", 100)}
+
+	tests := []struct {
+		name     string
+		nodes    []placement.Node
+		replicas []string
+		// stripe lists the nodes holding the other shards of the stripe.
+		stripe   []string
+		wantFrom []string
+	}{
+		{name: "balanced", nodes: balanced, replicas: []string{"a", "b"}},
+		{name: "skewed, no replica overfull", nodes: skewed, replicas: []string{"b", "c"}},
+		{name: "skewed, one replica overfull", nodes: skewed, replicas: []string{"a", "b"}, wantFrom: []string{"a"}},
+		{name: "skewed, both replicas overfull", nodes: skewed, replicas: []string{"a", "e"}, wantFrom: []string{"a", "e"}},
+		{name: "overfull shard", nodes: skewed, replicas: []string{"a"}, stripe: []string{"b", "c"}, wantFrom: []string{"a"}},
+		{name: "no room for the shard", nodes: skewed, replicas: []string{"a"}, stripe: []string{"b", "c", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := placement.NewCapacity(0.9)
+			r := &Rebalancer{placement: policy, replicationFactor: len(tt.replicas)}
+
+			chunk := storage.ChunkMetadata{ChunkHash: "chunk", Replicas: tt.replicas, StripeNumber: storage.NoStripe}
+			var members []storage.ChunkMetadata
+			if tt.stripe != nil {
+				chunk.StripeNumber = 0
+				members = append(members, chunk)
+				for i, serviceName := range tt.stripe {
+					members = append(members, storage.ChunkMetadata{ShardIndex: int32(i + 1), Replicas: []string{serviceName}})
+				}
+			}
+
+			moves := r.plan(chunk, members, tt.nodes)
+			var from []string
+			for _, move := range moves {
+				from = append(from, move.from)
+				if slices.Contains(tt.replicas, move.to) || slices.Contains(tt.stripe, move.to) {
+					t.Errorf("move %v goes to a node that already holds the chunk or its stripe", move)
+				}
+				for _, node := range tt.nodes {
+					if node.Name == move.to && policy.Overfull(node) {
+						t.Errorf("move %v goes to an overfull node", move)
+					}
+				}
+			}
+			if !slices.Equal(from, tt.wantFrom) {
+				t.Errorf("plan() moves replicas off %v, want %v", from, tt.wantFrom)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	filetransfer "s3-example/api/gen/go"
+	"s3-example/internal/clients"
+	"s3-example/internal/placement"
+	"s3-example/internal/storage"
+)
+
+const repairBatchSize = 500
+
+// ChunkReader returns a chunk the way it is stored on the storage nodes,
+// verified against its stored hash, from any of its replicas or, for
+// erasure-coded chunks, by reconstructing it.
+type ChunkReader func(ctx context.Context, metadata storage.ChunkMetadata) ([]byte, error)
+
+// RepairStatus describes the current or last repair pass.
+type RepairStatus struct {
+	Running         bool      `json:"running"`
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	ChunksScanned   int64     `json:"chunks_scanned"`
+	UnderReplicated int64     `json:"under_replicated"`
+	Repaired        int64     `json:"repaired"`
+	Failed          int64     `json:"failed"`
+	LastError       string    `json:"last_error,omitempty"`
+}
+
+// Repairer restores the redundancy of chunks that lost replicas because a
+// node died, a scrubber found them corrupt or their node is being drained.
+// Replicated chunks should live on replicationFactor nodes that are neither
+// dead nor draining; erasure-coded chunks on one such node that holds no
+// other shard of the stripe. Missing copies are written to nodes chosen by
+// the placement policy.
+type Repairer struct {
+	dbManager         *storage.Manager
+	grpcClientManager *clients.GrpcClientManager
+	placement         placement.Placement
+	readChunk         ChunkReader
+	replicationFactor int
+	interval          time.Duration
+	trigger           chan struct{}
+
+	mu     sync.Mutex
+	status RepairStatus
+
+	// busyChunks holds the chunks being repaired, by the repair pass or the
+	// drainer, each with a channel closed once its repair ends.
+	chunksMu   sync.Mutex
+	busyChunks map[int64]chan struct{}
+}
+
+func NewRepairer(dbManager *storage.Manager, grpcClientManager *clients.GrpcClientManager, chunkPlacement placement.Placement, readChunk ChunkReader, replicationFactor int, interval time.Duration) *Repairer {
+	return &Repairer{
+		dbManager:         dbManager,
+		grpcClientManager: grpcClientManager,
+		placement:         chunkPlacement,
+		readChunk:         readChunk,
+		replicationFactor: max(replicationFactor, 1),
+		interval:          interval,
+		trigger:           make(chan struct{}, 1),
+		busyChunks:        make(map[int64]chan struct{}),
+	}
+}
+
+// Run repairs chunks every interval, or whenever Trigger is called. A zero
+// interval disables periodic repair.
+func (r *Repairer) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if r.interval > 0 {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-r.trigger:
+		}
+
+		r.repair(ctx)
+	}
+}
+
+// Trigger starts a repair pass as soon as the current one, if any, ends.
+func (r *Repairer) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Repairer) Status() RepairStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.status
+}
+
+func (r *Repairer) update(f func(status *RepairStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f(&r.status)
+}
+
+func (r *Repairer) repair(ctx context.Context) {
+	r.update(func(status *RepairStatus) {
+		*status = RepairStatus{Running: true, StartedAt: time.Now()}
+	})
+
+	err := r.repairAll(ctx)
+
+	r.update(func(status *RepairStatus) {
+		status.Running = false
+		status.FinishedAt = time.Now()
+		if err != nil {
+			status.LastError = err.Error()
+		}
+	})
+
+	status := r.Status()
+	if err != nil {
+		log.Printf("Error repairing chunks: %v", err)
+	}
+	if status.UnderReplicated > 0 {
+		log.Printf("Repair finished: %d chunks scanned, %d under-replicated, %d repaired, %d failed",
+			status.ChunksScanned, status.UnderReplicated, status.Repaired, status.Failed)
+	}
+}
+
+func (r *Repairer) repairAll(ctx context.Context) error {
+	afterID := int64(0)
+	for {
+		chunks, err := r.dbManager.ListChunks(afterID, repairBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+
+		for _, chunk := range chunks {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			afterID = chunk.ID
+
+			repaired, err := r.repairChunk(ctx, chunk)
+			r.update(func(status *RepairStatus) {
+				status.ChunksScanned++
+				switch {
+				case err != nil:
+					status.UnderReplicated++
+					status.Failed++
+					status.LastError = err.Error()
+				case repaired:
+					status.UnderReplicated++
+					status.Repaired++
+				}
+			})
+			if err != nil {
+				log.Printf("Error repairing chunk %s: %v", chunk.ChunkHash, err)
+			}
+		}
+	}
+}
+
+// lockChunk waits until no one else repairs the chunk and returns the
+// function that releases it.
+func (r *Repairer) lockChunk(chunkID int64) func() {
+	for {
+		r.chunksMu.Lock()
+		done, busy := r.busyChunks[chunkID]
+		if !busy {
+			done = make(chan struct{})
+			r.busyChunks[chunkID] = done
+			r.chunksMu.Unlock()
+
+			return func() {
+				r.chunksMu.Lock()
+				delete(r.busyChunks, chunkID)
+				r.chunksMu.Unlock()
+				close(done)
+			}
+		}
+		r.chunksMu.Unlock()
+		<-done
+	}
+}
+
+// repairChunk copies the chunk to as many new nodes as it is missing
+// replicas. It reports whether the chunk needed repair. The repair pass and
+// the drainer both call it, so it locks the chunk and reloads its replicas
+// to see the copies the other one may have just made.
+func (r *Repairer) repairChunk(ctx context.Context, listed storage.ChunkMetadata) (bool, error) {
+	unlock := r.lockChunk(listed.ID)
+	defer unlock()
+
+	current, err := r.dbManager.GetChunk(listed.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	chunk := *current
+
+	target := r.replicationFactor
+	if chunk.StripeNumber != storage.NoStripe {
+		target = 1
+	}
+
+	live := 0
+	for _, serviceName := range chunk.Replicas {
+		if isLive(r.grpcClientManager, serviceName) {
+			live++
+		}
+	}
+	if live >= target {
+		return false, nil
+	}
+
+	excluded := make(map[string]bool)
+	for _, serviceName := range chunk.Replicas {
+		excluded[serviceName] = true
+	}
+	if chunk.StripeNumber != storage.NoStripe {
+		members, err := r.dbManager.GetStripeChunkMetadata(chunk)
+		if err != nil {
+			return false, fmt.Errorf("Error getting stripe metadata: %v", err)
+		}
+		for _, member := range members {
+			for _, serviceName := range member.Replicas {
+				excluded[serviceName] = true
+			}
+		}
+	}
+
+	targets, err := r.placeCopies(chunk.ChunkHash, excluded, target-live)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := r.readChunk(ctx, chunk)
+	if err != nil {
+		return false, err
+	}
+
+	for _, serviceName := range targets {
+		if err := r.copyChunk(ctx, chunk, data, serviceName); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// isLive reports whether a replica on the node counts towards the chunk's
+// redundancy. Replicas on dead, unknown and draining nodes do not.
+func isLive(grpcClientManager *clients.GrpcClientManager, serviceName string) bool {
+	node, ok := grpcClientManager.GetNode(serviceName)
+	return ok && node.State != clients.NodeDead && !node.Draining
+}
+
+// placeCopies picks count healthy nodes outside excluded for new copies of
+// the chunk.
+func (r *Repairer) placeCopies(chunkHash string, excluded map[string]bool, count int) ([]string, error) {
+	var candidates []placement.Node
+	for _, node := range r.grpcClientManager.GetNodes() {
+		if !excluded[node.Name] {
+			candidates = append(candidates, node)
+		}
+	}
+
+	targets, err := r.placement.Place(chunkHash, candidates, count)
+	if errors.Is(err, placement.ErrNotEnoughNodes) {
+		return nil, fmt.Errorf("no healthy node available for %d more copies", count)
+	}
+	return targets, err
+}
+
+// copyChunk records the new replica, writes the chunk to the node and then
+// marks it stored. Recording it first keeps the chunk file referenced, so a
+// pending purge of the same file cannot delete the copy just written. If
+// the write fails, the replica is dropped again.
+func (r *Repairer) copyChunk(ctx context.Context, chunk storage.ChunkMetadata, data []byte, serviceName string) error {
+	client := r.grpcClientManager.GetClientByName(serviceName)
+	if client == nil {
+		return fmt.Errorf("gRPC client not found for service: %s", serviceName)
+	}
+
+	if err := r.dbManager.AddChunkReplica(chunk.ID, serviceName, chunk.StoredHash); err != nil {
+		return err
+	}
+
+	err := r.grpcClientManager.PutChunk(ctx, client, &filetransfer.FileChunk{
+		Chunk:       data,
+		ChunkNumber: chunk.ChunkNumber,
+		ChunkHash:   chunk.StoredHash,
+	})
+	if err != nil {
+		if err := r.dbManager.RemoveChunkReplica(chunk.ID, serviceName); err != nil {
+			log.Printf("Error dropping replica of chunk %s on %s: %v", chunk.ChunkHash, serviceName, err)
+		}
+		return fmt.Errorf("Error sending chunk to %s: %v", serviceName, err)
+	}
+
+	return r.dbManager.MarkChunkBlobStored(serviceName, chunk.StoredHash)
+}
@@ -0,0 +1,109 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// None stores chunks as they are.
+	None = "none"
+	Zstd = "zstd"
+	Gzip = "gzip"
+)
+
+// minSavings is the share of a chunk compression has to save for the
+// compressed form to be stored. Below that, decompressing on every read
+// is not worth the space.
+const minSavings = 0.1
+
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+func ValidCodec(codec string) bool {
+	return codec == None || codec == Zstd || codec == Gzip
+}
+
+// Compress compresses data with codec. If that does not save at least
+// minSavings, data is returned unchanged with codec None. The returned
+// codec is the one to record for the chunk.
+func Compress(codec string, data []byte) ([]byte, string, error) {
+	if codec == None || codec == "" || len(data) == 0 {
+		return data, None, nil
+	}
+
+	compressed, err := Encode(codec, data)
+	if err != nil {
+		return nil, "", err
+	}
+	if float64(len(compressed)) > float64(len(data))*(1-minSavings) {
+		return data, None, nil
+	}
+	return compressed, codec, nil
+}
+
+// Encode compresses data with codec unconditionally. The output only
+// depends on the input, so a chunk compressed twice hashes the same.
+func Encode(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case None, "":
+		return data, nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	case Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression codec %q", codec)
+	}
+}
+
+// Decompress reverses Encode. size is the length of the original data;
+// output of any other length is an error. Output is read through a limit
+// of size+1 bytes, so corrupt or hostile input cannot make it allocate more.
+func Decompress(codec string, data []byte, size int64) ([]byte, error) {
+	var r io.Reader
+	switch codec {
+	case None, "":
+		if int64(len(data)) != size {
+			return nil, fmt.Errorf("decompressed %d bytes, expected %d", len(data), size)
+		}
+		return data, nil
+	case Zstd:
+		d, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		r = d
+	case Gzip:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	default:
+		return nil, fmt.Errorf("unknown compression codec %q", codec)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	// Read one byte past size to detect longer output.
+	if _, err := io.Copy(buf, io.LimitReader(r, size+1)); err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) != size {
+		return nil, fmt.Errorf("decompressed %d bytes, expected %d", buf.Len(), size)
+	}
+	return buf.Bytes(), nil
+}
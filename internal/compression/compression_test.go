@@ -0,0 +1,120 @@
+package compression
+
+import (
+	"bytes"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	random := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(random)
+	text := bytes.Repeat([]byte("the same line over and over again\n"), 2000)
+
+	tests := []struct {
+		name      string
+		codec     string
+		data      []byte
+		wantCodec string
+	}{
+		{name: "zstd", codec: Zstd, data: text, wantCodec: Zstd},
+		{name: "gzip", codec: Gzip, data: text, wantCodec: Gzip},
+		{name: "none", codec: None, data: text, wantCodec: None},
+		{name: "zstd incompressible", codec: Zstd, data: random, wantCodec: None},
+		{name: "gzip incompressible", codec: Gzip, data: random, wantCodec: None},
+		{name: "empty", codec: Zstd, data: nil, wantCodec: None},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed, codec, err := Compress(tt.codec, tt.data)
+			if err != nil {
+				t.Fatalf("Compress() error = %v", err)
+			}
+			if codec != tt.wantCodec {
+				t.Fatalf("Compress() codec = %q, want %q", codec, tt.wantCodec)
+			}
+
+			got, err := Decompress(codec, compressed, int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Decompress() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("Decompress() returned %d bytes differing from the %d compressed", len(got), len(tt.data))
+			}
+		})
+	}
+}
+
+func TestDecompressRejectsBadInput(t *testing.T) {
+	data := bytes.Repeat([]byte("chunk data "), 1000)
+	size := int64(len(data))
+
+	encode := func(codec string) []byte {
+		encoded, err := Encode(codec, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+	corrupt := func(codec string) []byte {
+		encoded := bytes.Clone(encode(codec))
+		for i := len(encoded) / 2; i < len(encoded); i++ {
+			encoded[i] ^= 0x5a
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name  string
+		codec string
+		data  []byte
+		size  int64
+	}{
+		{name: "zstd garbage", codec: Zstd, data: []byte("not zstd at all"), size: size},
+		{name: "gzip garbage", codec: Gzip, data: []byte("not gzip at all"), size: size},
+		{name: "zstd corrupt", codec: Zstd, data: corrupt(Zstd), size: size},
+		{name: "gzip corrupt", codec: Gzip, data: corrupt(Gzip), size: size},
+		{name: "zstd truncated", codec: Zstd, data: encode(Zstd)[:20], size: size},
+		{name: "gzip truncated", codec: Gzip, data: encode(Gzip)[:20], size: size},
+		{name: "zstd longer than recorded", codec: Zstd, data: encode(Zstd), size: size - 1},
+		{name: "gzip longer than recorded", codec: Gzip, data: encode(Gzip), size: size - 1},
+		{name: "zstd shorter than recorded", codec: Zstd, data: encode(Zstd), size: size + 1},
+		{name: "gzip shorter than recorded", codec: Gzip, data: encode(Gzip), size: size + 1},
+		{name: "none with another size", codec: None, data: data, size: size + 1},
+		{name: "unknown codec", codec: "lz4", data: data, size: size},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Decompress(tt.codec, tt.data, tt.size); err == nil {
+				t.Errorf("Decompress() = %d bytes, want an error", len(got))
+			}
+		})
+	}
+}
+
+// TestDecompressBoundsOutput checks that a small input claiming a huge
+// output is not decompressed past the recorded size.
+func TestDecompressBoundsOutput(t *testing.T) {
+	for _, codec := range []string{Zstd, Gzip} {
+		t.Run(codec, func(t *testing.T) {
+			bomb, err := Encode(codec, make([]byte, 64<<20))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			got, err := Decompress(codec, bomb, 1024)
+			runtime.ReadMemStats(&after)
+			if err == nil {
+				t.Fatalf("Decompress() = %d bytes, want an error", len(got))
+			}
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+				t.Errorf("Decompress() allocated %d bytes for a 1024-byte chunk", allocated)
+			}
+		})
+	}
+}
@@ -35,3 +35,15 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	}
 	return value
 }
+
+func getEnvAsFloat64(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -1,10 +1,21 @@
 package config
 
+import "fmt"
+
 type StorageServiceConfig struct {
 	GRPCPort           string
 	TransferServiceURL string
 	StorageDir         string
 	ServiceName        string
+	Weight             float64
+	// RegistrationCheckInterval is how often, in seconds, the node asks the
+	// transfer service whether it is still registered.
+	RegistrationCheckInterval int
+	// ScrubInterval is the pause, in seconds, between scrubber passes;
+	// zero disables the scrubber.
+	ScrubInterval int
+	// ScrubBytesPerSecond limits how fast the scrubber reads chunk files.
+	ScrubBytesPerSecond int64
 }
 
 func LoadStorageConfig() (*StorageServiceConfig, error) {
@@ -12,11 +23,23 @@ func LoadStorageConfig() (*StorageServiceConfig, error) {
 	transferServiceURL := getEnv("TRANSFER_SERVICE_URL", "http://transfer_service:8080")
 	storageDir := getEnv("STORAGE_DIR", "./storage")
 	serviceName := getEnv("SERVICE_NAME", "default_service_name")
+	weight := getEnvAsFloat64("NODE_WEIGHT", 1)
+	registrationCheckInterval := getEnvAsInt("REGISTRATION_CHECK_INTERVAL_SECONDS", 30)
+	scrubInterval := getEnvAsInt("SCRUB_INTERVAL_SECONDS", 3600)
+	scrubBytesPerSecond := getEnvAsInt64("SCRUB_RATE_MB_PER_SECOND", 10) * 1024 * 1024
+
+	if registrationCheckInterval < 1 {
+		return nil, fmt.Errorf("invalid REGISTRATION_CHECK_INTERVAL_SECONDS %d", registrationCheckInterval)
+	}
 
 	return &StorageServiceConfig{
-		GRPCPort:           grpcPort,
-		TransferServiceURL: transferServiceURL,
-		StorageDir:         storageDir,
-		ServiceName:        serviceName,
+		GRPCPort:                  grpcPort,
+		TransferServiceURL:        transferServiceURL,
+		StorageDir:                storageDir,
+		ServiceName:               serviceName,
+		Weight:                    weight,
+		RegistrationCheckInterval: registrationCheckInterval,
+		ScrubInterval:             scrubInterval,
+		ScrubBytesPerSecond:       scrubBytesPerSecond,
 	}, nil
 }
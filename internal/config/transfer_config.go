@@ -1,31 +1,129 @@
 package config
 
+import (
+	"fmt"
+
+	"s3-example/internal/chunker"
+	"s3-example/internal/compression"
+)
+
+const (
+	RedundancyReplication = "replication"
+	RedundancyErasure     = "erasure"
+)
+
+const (
+	PlacementConsistentHash = "consistent-hash"
+	PlacementCapacity       = "capacity"
+)
+
 type TransferServiceConfig struct {
-	ServerPort       string
-	GRPCPort         string
-	RedisAddr        string
-	SessionTTL       int
-	MaxUploadSize    int64
-	ChunkSize        int
-	PostgresHost     string
-	PostgresPort     string
-	PostgresUser     string
-	PostgresPassword string
-	PostgresDBName   string
+	ServerPort          string
+	GRPCPort            string
+	RedisAddr           string
+	SessionTTL          int
+	MaxUploadSize       int64
+	ChunkSize           int
+	ChunkingMode        string
+	CDCMinSize          int
+	CDCAvgSize          int
+	CDCMaxSize          int
+	Compression         string
+	SSEMasterKeyFile    string
+	ReplicationFactor   int
+	RedundancyMode      string
+	ECDataShards        int
+	ECParityShards      int
+	PlacementPolicy     string
+	VirtualNodes        int
+	HighWaterMark       float64
+	NodeStatsInterval   int
+	HeartbeatInterval   int
+	NodeDeadAfter       int
+	NodeDeregisterAfter int
+	UploadWindow        int
+	DownloadWindow      int
+	PurgeInterval       int
+	RepairInterval      int
+	DrainInterval       int
+	RebalanceInterval   int
+	RebalanceBatchSize  int
+	RebalancePause      int
+	PostgresHost        string
+	PostgresPort        string
+	PostgresUser        string
+	PostgresPassword    string
+	PostgresDBName      string
 }
 
 func LoadTransferConfig() (*TransferServiceConfig, error) {
-	return &TransferServiceConfig{
-		ServerPort:       getEnv("SERVER_PORT", "8080"),
-		GRPCPort:         getEnv("GRPC_PORT", "5001"),
-		RedisAddr:        getEnv("REDIS_ADDR", "localhost:6379"),
-		SessionTTL:       getEnvAsInt("SESSION_TTL", 3600),
-		MaxUploadSize:    getEnvAsInt64("MAX_UPLOAD_SIZE_GB", 2) * 1024 * 1024 * 1024,
-		ChunkSize:        int(getEnvAsInt64("CHUNK_SIZE_BYTES", 1048576)),
-		PostgresHost:     getEnv("POSTGRES_HOST", "localhost"),
-		PostgresPort:     getEnv("POSTGRES_PORT", "5432"),
-		PostgresUser:     getEnv("POSTGRES_USER", "user"),
-		PostgresPassword: getEnv("POSTGRES_PASSWORD", "password"),
-		PostgresDBName:   getEnv("POSTGRES_DB", "dbname"),
-	}, nil
+	cfg := &TransferServiceConfig{
+		ServerPort:          getEnv("SERVER_PORT", "8080"),
+		GRPCPort:            getEnv("GRPC_PORT", "5001"),
+		RedisAddr:           getEnv("REDIS_ADDR", "localhost:6379"),
+		SessionTTL:          getEnvAsInt("SESSION_TTL", 3600),
+		MaxUploadSize:       getEnvAsInt64("MAX_UPLOAD_SIZE_GB", 2) * 1024 * 1024 * 1024,
+		ChunkSize:           int(getEnvAsInt64("CHUNK_SIZE_BYTES", 1048576)),
+		ChunkingMode:        getEnv("CHUNKING_MODE", chunker.ModeFixed),
+		CDCMinSize:          int(getEnvAsInt64("CDC_MIN_SIZE_BYTES", 262144)),
+		CDCAvgSize:          int(getEnvAsInt64("CDC_AVG_SIZE_BYTES", 1048576)),
+		CDCMaxSize:          int(getEnvAsInt64("CDC_MAX_SIZE_BYTES", 4194304)),
+		Compression:         getEnv("CHUNK_COMPRESSION", compression.None),
+		SSEMasterKeyFile:    getEnv("SSE_MASTER_KEY_FILE", ""),
+		ReplicationFactor:   getEnvAsInt("REPLICATION_FACTOR", 1),
+		RedundancyMode:      getEnv("REDUNDANCY_MODE", RedundancyReplication),
+		ECDataShards:        getEnvAsInt("EC_DATA_SHARDS", 4),
+		ECParityShards:      getEnvAsInt("EC_PARITY_SHARDS", 2),
+		PlacementPolicy:     getEnv("PLACEMENT_POLICY", PlacementConsistentHash),
+		VirtualNodes:        getEnvAsInt("PLACEMENT_VIRTUAL_NODES", 128),
+		HighWaterMark:       getEnvAsFloat64("PLACEMENT_HIGH_WATER_MARK", 0.9),
+		NodeStatsInterval:   getEnvAsInt("NODE_STATS_INTERVAL_SECONDS", 30),
+		HeartbeatInterval:   getEnvAsInt("HEARTBEAT_INTERVAL_SECONDS", 5),
+		NodeDeadAfter:       getEnvAsInt("NODE_DEAD_AFTER_SECONDS", 30),
+		NodeDeregisterAfter: getEnvAsInt("NODE_DEREGISTER_AFTER_SECONDS", 600),
+		UploadWindow:        getEnvAsInt("UPLOAD_WINDOW_CHUNKS", 8),
+		DownloadWindow:      getEnvAsInt("DOWNLOAD_WINDOW_CHUNKS", 4),
+		PurgeInterval:       getEnvAsInt("PURGE_INTERVAL_SECONDS", 10),
+		RepairInterval:      getEnvAsInt("REPAIR_INTERVAL_SECONDS", 300),
+		DrainInterval:       getEnvAsInt("DRAIN_INTERVAL_SECONDS", 60),
+		RebalanceInterval:   getEnvAsInt("REBALANCE_INTERVAL_SECONDS", 3600),
+		RebalanceBatchSize:  getEnvAsInt("REBALANCE_BATCH_SIZE", 20),
+		RebalancePause:      getEnvAsInt("REBALANCE_PAUSE_SECONDS", 5),
+		PostgresHost:        getEnv("POSTGRES_HOST", "localhost"),
+		PostgresPort:        getEnv("POSTGRES_PORT", "5432"),
+		PostgresUser:        getEnv("POSTGRES_USER", "user"),
+		PostgresPassword:    getEnv("POSTGRES_PASSWORD", "password"),
+		PostgresDBName:      getEnv("POSTGRES_DB", "dbname"),
+	}
+
+	if cfg.SessionTTL < 1 {
+		return nil, fmt.Errorf("invalid SESSION_TTL %d", cfg.SessionTTL)
+	}
+
+	switch cfg.RedundancyMode {
+	case RedundancyReplication:
+	case RedundancyErasure:
+		if cfg.ECDataShards < 1 || cfg.ECParityShards < 1 || cfg.ECDataShards+cfg.ECParityShards > 256 {
+			return nil, fmt.Errorf("invalid erasure coding layout %d+%d", cfg.ECDataShards, cfg.ECParityShards)
+		}
+	default:
+		return nil, fmt.Errorf("unknown REDUNDANCY_MODE %q", cfg.RedundancyMode)
+	}
+
+	if cfg.PlacementPolicy != PlacementConsistentHash && cfg.PlacementPolicy != PlacementCapacity {
+		return nil, fmt.Errorf("unknown PLACEMENT_POLICY %q", cfg.PlacementPolicy)
+	}
+
+	if !chunker.ValidMode(cfg.ChunkingMode) {
+		return nil, fmt.Errorf("unknown CHUNKING_MODE %q", cfg.ChunkingMode)
+	}
+	if cfg.CDCMinSize < 1 || cfg.CDCMinSize > cfg.CDCAvgSize || cfg.CDCAvgSize > cfg.CDCMaxSize {
+		return nil, fmt.Errorf("invalid content-defined chunk sizes %d/%d/%d", cfg.CDCMinSize, cfg.CDCAvgSize, cfg.CDCMaxSize)
+	}
+
+	if !compression.ValidCodec(cfg.Compression) {
+		return nil, fmt.Errorf("unknown CHUNK_COMPRESSION %q", cfg.Compression)
+	}
+
+	return cfg, nil
 }
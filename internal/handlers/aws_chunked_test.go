@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestAWSChunkedReader(t *testing.T) {
+	const signature = ";chunk-signature=0123456789abcdef"
+
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "single chunk",
+			body: "5\r\nhello\r\n0\r\n\r\n",
+			want: "hello",
+		},
+		{
+			name: "multiple chunks",
+			body: "5\r\nhello\r\n1\r\n \r\n5\r\nworld\r\n0\r\n\r\n",
+			want: "hello world",
+		},
+		{
+			name: "signed chunks",
+			body: "5" + signature + "\r\nhello\r\n0" + signature + "\r\n\r\n",
+			want: "hello",
+		},
+		{
+			name: "hex sizes",
+			body: "a\r\n0123456789\r\n0\r\n\r\n",
+			want: "0123456789",
+		},
+		{
+			name: "trailers",
+			body: "5\r\nhello\r\n0\r\nx-amz-checksum-crc32:AAAAAA==\r\n\r\n",
+			want: "hello",
+		},
+		{
+			name: "no final blank line",
+			body: "5\r\nhello\r\n0\r\n",
+			want: "hello",
+		},
+		{
+			name: "empty payload",
+			body: "0\r\n\r\n",
+			want: "",
+		},
+		{
+			name:    "truncated data",
+			body:    "a\r\nhello",
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "missing final chunk",
+			body:    "5\r\nhello\r\n",
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "data longer than size",
+			body:    "3\r\nhello\r\n0\r\n\r\n",
+			wantErr: errMalformedChunk,
+		},
+		{
+			name:    "bad size",
+			body:    "zz\r\nhello\r\n0\r\n\r\n",
+			wantErr: errMalformedChunk,
+		},
+		{
+			name:    "negative size",
+			body:    "-5\r\nhello\r\n0\r\n\r\n",
+			wantErr: errMalformedChunk,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One byte at a time catches state kept wrongly between reads.
+			for _, r := range []io.Reader{strings.NewReader(tt.body), iotest.OneByteReader(strings.NewReader(tt.body))} {
+				got, err := io.ReadAll(newAWSChunkedReader(r))
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("error = %v, want %v", err, tt.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("error = %v", err)
+				}
+				if string(got) != tt.want {
+					t.Errorf("read %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"s3-example/internal/clients"
+	"s3-example/internal/cluster"
+	"s3-example/internal/storage"
+)
+
+// ClusterHandler serves the endpoints storage nodes and operators use to
+// maintain the cluster, as opposed to client object operations.
+type ClusterHandler struct {
+	dbManager         *storage.Manager
+	grpcClientManager *clients.GrpcClientManager
+	repairer          *cluster.Repairer
+	drainer           *cluster.Drainer
+	rebalancer        *cluster.Rebalancer
+}
+
+func NewClusterHandler(dbManager *storage.Manager, grpcClientManager *clients.GrpcClientManager, repairer *cluster.Repairer, drainer *cluster.Drainer, rebalancer *cluster.Rebalancer) *ClusterHandler {
+	return &ClusterHandler{
+		dbManager:         dbManager,
+		grpcClientManager: grpcClientManager,
+		repairer:          repairer,
+		drainer:           drainer,
+		rebalancer:        rebalancer,
+	}
+}
+
+// CorruptChunkHandler is called by a storage node's scrubber after it
+// quarantined a chunk file. The node's replicas of that chunk are dropped,
+// so downloads stop reading them, and a repair pass is started to replace
+// them.
+func (h *ClusterHandler) CorruptChunkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ServiceName string `json:"service_name"`
+		ChunkHash   string `json:"chunk_hash"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ServiceName == "" || req.ChunkHash == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := h.dbManager.RemoveChunkReplicas(req.ServiceName, req.ChunkHash)
+	if err != nil {
+		http.Error(w, "Error removing chunk replicas: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Storage node %s reported corrupt chunk %s, %d replicas removed", req.ServiceName, req.ChunkHash, removed)
+	if removed > 0 {
+		h.repairer.Trigger()
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Corrupt chunk recorded"))
+}
+
+// RepairHandler reports the progress of the current or last repair pass on
+// GET and starts a new pass on POST.
+func (h *ClusterHandler) RepairHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		h.repairer.Trigger()
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Repair scheduled"))
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response, err := json.Marshal(h.repairer.Status())
+	if err != nil {
+		http.Error(w, "Error forming response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+type drainStatus struct {
+	ServiceName     string            `json:"service_name"`
+	State           clients.NodeState `json:"state"`
+	RemainingChunks int64             `json:"remaining_chunks"`
+}
+
+// DrainHandler manages node decommissioning: GET lists the draining nodes
+// with the number of chunks still on them, POST starts draining the node
+// named in the body and DELETE ?service_name= cancels it. A drained node is
+// deregistered automatically.
+func (h *ClusterHandler) DrainHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listDraining(w)
+	case http.MethodPost:
+		var req struct {
+			ServiceName string `json:"service_name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if !h.setDraining(w, req.ServiceName, true) {
+			return
+		}
+		h.drainer.Trigger()
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Node is draining"))
+	case http.MethodDelete:
+		if !h.setDraining(w, r.URL.Query().Get("service_name"), false) {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Drain cancelled"))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *ClusterHandler) setDraining(w http.ResponseWriter, serviceName string, draining bool) bool {
+	err := h.grpcClientManager.SetDraining(serviceName, draining)
+	if errors.Is(err, clients.ErrClientNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Error updating node: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	log.Printf("Storage node %s draining: %v", serviceName, draining)
+	return true
+}
+
+func (h *ClusterHandler) listDraining(w http.ResponseWriter) {
+	nodes := []drainStatus{}
+	for _, node := range h.grpcClientManager.ListNodes() {
+		if !node.Draining {
+			continue
+		}
+
+		remaining, err := h.dbManager.CountNodeReplicas(node.ServiceName)
+		if err != nil {
+			http.Error(w, "Error counting chunks: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		nodes = append(nodes, drainStatus{
+			ServiceName:     node.ServiceName,
+			State:           node.State,
+			RemainingChunks: remaining,
+		})
+	}
+
+	response, err := json.Marshal(nodes)
+	if err != nil {
+		http.Error(w, "Error forming response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// RebalanceHandler reports the progress of the current or last rebalancing
+// pass on GET and starts a new pass on POST.
+func (h *ClusterHandler) RebalanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		h.rebalancer.Trigger()
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Rebalance scheduled"))
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response, err := json.Marshal(h.rebalancer.Status())
+	if err != nil {
+		http.Error(w, "Error forming response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	filetransfer "s3-example/api/gen/go"
+	"s3-example/internal/compression"
+	"s3-example/internal/sse"
+	"s3-example/internal/storage"
+)
+
+// serveObject writes the object made of chunks to w, honouring Range and
+// If-Range. Headers are only sent once the first chunk has been fetched, so
+// an early failure still produces a proper error response; the returned
+// error is for logging only. HEAD requests are answered from metadata alone.
+func (h *FileHandler) serveObject(w http.ResponseWriter, r *http.Request, file *storage.FileMetadata, chunks []storage.ChunkMetadata, key []byte) error {
+	filename := file.Filename
+	size := file.TotalSize
+	contentType := file.ContentType
+	etag := file.ETag
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", file.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	ranges, err := parseRange(r.Header.Get("Range"), size)
+	if err != nil {
+		if errors.Is(err, errNoOverlap) {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		}
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return err
+	}
+	if !ifRangeMatches(r.Header.Get("If-Range"), etag, file.UpdatedAt) {
+		ranges = nil
+	}
+
+	grpcClients := h.grpcClientManager.GetClientsByName()
+	if len(grpcClients) == 0 {
+		err := errors.New("No available gRPC connections")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
+	started := false
+	switch len(ranges) {
+	case 0:
+		err = h.copyChunks(r.Context(), filename, key, fullSlices(chunks), grpcClients, func() (io.Writer, error) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+			w.WriteHeader(http.StatusOK)
+			started = true
+			return w, nil
+		})
+	case 1:
+		rng := ranges[0]
+		err = h.copyChunks(r.Context(), filename, key, rangeSlices(chunks, rng), grpcClients, func() (io.Writer, error) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Range", rng.contentRange(size))
+			w.Header().Set("Content-Length", strconv.FormatInt(rng.length, 10))
+			w.WriteHeader(http.StatusPartialContent)
+			started = true
+			return w, nil
+		})
+	default:
+		mw := multipart.NewWriter(w)
+		for _, rng := range ranges {
+			err = h.copyChunks(r.Context(), filename, key, rangeSlices(chunks, rng), grpcClients, func() (io.Writer, error) {
+				if !started {
+					w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+					w.WriteHeader(http.StatusPartialContent)
+					started = true
+				}
+				return mw.CreatePart(textproto.MIMEHeader{
+					"Content-Type":  {contentType},
+					"Content-Range": {rng.contentRange(size)},
+				})
+			})
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+	}
+
+	if err != nil && !started {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return err
+}
+
+// copyChunks streams the given chunk slices, in order, to the writer returned
+// by open. open is called right before the first byte is written.
+func (h *FileHandler) copyChunks(ctx context.Context, filename string, key []byte, slices []chunkSlice, grpcClients map[string]filetransfer.FileTransferServiceClient, open func() (io.Writer, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make([]storage.ChunkMetadata, len(slices))
+	for i, slice := range slices {
+		chunks[i] = slice.metadata
+	}
+
+	var w io.Writer
+	written := 0
+	for slot := range h.fetchChunks(ctx, filename, key, chunks, grpcClients, h.cfg.DownloadWindow) {
+		res := <-slot
+		if res.err != nil {
+			return res.err
+		}
+
+		slice := slices[written]
+		if int64(len(res.data)) < slice.offset+slice.length {
+			return fmt.Errorf("Chunk %d is shorter than recorded", res.chunkNumber)
+		}
+
+		if w == nil {
+			var err error
+			if w, err = open(); err != nil {
+				return err
+			}
+		}
+
+		if _, err := w.Write(res.data[slice.offset : slice.offset+slice.length]); err != nil {
+			return err
+		}
+		written++
+	}
+
+	if written < len(slices) {
+		return ctx.Err()
+	}
+	if w == nil {
+		_, err := open()
+		return err
+	}
+	return nil
+}
+
+type chunkResult struct {
+	chunkNumber int32
+	data        []byte
+	err         error
+}
+
+// fetchChunks fetches chunks concurrently, keeping at most window of them in
+// flight or waiting to be consumed, and yields them in the order given.
+// Cancelling ctx stops the prefetching.
+func (h *FileHandler) fetchChunks(ctx context.Context, filename string, key []byte, chunks []storage.ChunkMetadata, grpcClients map[string]filetransfer.FileTransferServiceClient, window int) <-chan chan chunkResult {
+	if window < 1 {
+		window = 1
+	}
+
+	slots := make(chan chan chunkResult, window-1)
+
+	go func() {
+		defer close(slots)
+
+		for _, metadata := range chunks {
+			slot := make(chan chunkResult, 1)
+			select {
+			case slots <- slot:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(metadata storage.ChunkMetadata) {
+				slot <- h.fetchChunk(ctx, filename, key, metadata, grpcClients)
+			}(metadata)
+		}
+	}()
+
+	return slots
+}
+
+// fetchChunk reads the chunk and decodes it with the object's data key.
+func (h *FileHandler) fetchChunk(ctx context.Context, filename string, key []byte, metadata storage.ChunkMetadata, grpcClients map[string]filetransfer.FileTransferServiceClient) chunkResult {
+	data, err := h.fetchStoredChunk(ctx, filename, metadata, grpcClients)
+	if err == nil {
+		data, err = decodeChunk(metadata, data, key)
+	}
+	if err != nil {
+		return chunkResult{
+			chunkNumber: metadata.ChunkNumber,
+			err:         err,
+		}
+	}
+
+	return chunkResult{
+		chunkNumber: metadata.ChunkNumber,
+		data:        data,
+	}
+}
+
+// fetchStoredChunk reads the stored bytes of the chunk from one of its
+// replicas, falling back to reconstructing them from the chunk's stripe when
+// the chunk is erasure coded.
+func (h *FileHandler) fetchStoredChunk(ctx context.Context, filename string, metadata storage.ChunkMetadata, grpcClients map[string]filetransfer.FileTransferServiceClient) ([]byte, error) {
+	storedData, err := h.readStoredChunk(ctx, filename, metadata, grpcClients)
+	if err != nil && metadata.StripeNumber != storage.NoStripe && ctx.Err() == nil {
+		fmt.Printf("%v, reconstructing from stripe %d\n", err, metadata.StripeNumber)
+		storedData, err = h.reconstructChunk(ctx, filename, metadata, grpcClients)
+	}
+	return storedData, err
+}
+
+// ReadChunk returns any chunk, data or parity, the way it is stored on the
+// storage nodes, for copying it to another node. It needs no data keys.
+func (h *FileHandler) ReadChunk(ctx context.Context, metadata storage.ChunkMetadata) ([]byte, error) {
+	return h.fetchStoredChunk(ctx, "", metadata, h.grpcClientManager.GetClientsByName())
+}
+
+// decodeChunk turns the stored bytes of a chunk back into its data.
+func decodeChunk(metadata storage.ChunkMetadata, storedData, key []byte) ([]byte, error) {
+	data := storedData
+	if metadata.Encrypted {
+		if key == nil {
+			return nil, fmt.Errorf("Chunk %d is encrypted, but no data key was given", metadata.ChunkNumber)
+		}
+		var err error
+		data, err = sse.Open(key, storedData, []byte(metadata.ChunkHash))
+		if err != nil {
+			return nil, fmt.Errorf("Error decrypting chunk %d: %v", metadata.ChunkNumber, err)
+		}
+	}
+
+	data, err := compression.Decompress(metadata.Compression, data, metadata.ChunkSize)
+	if err != nil {
+		return nil, fmt.Errorf("Error decompressing chunk %d: %v", metadata.ChunkNumber, err)
+	}
+	return data, nil
+}
+
+// readStoredChunk reads the chunk from the first replica that returns data
+// with the recorded stored hash.
+func (h *FileHandler) readStoredChunk(ctx context.Context, filename string, metadata storage.ChunkMetadata, grpcClients map[string]filetransfer.FileTransferServiceClient) ([]byte, error) {
+	err := fmt.Errorf("No replicas recorded for chunk %d", metadata.ChunkNumber)
+
+	for _, serviceName := range metadata.Replicas {
+		client := grpcClients[serviceName]
+		if client == nil {
+			err = fmt.Errorf("gRPC client not found for service: %s", serviceName)
+			continue
+		}
+
+		chunkData, getErr := h.grpcClientManager.GetChunk(ctx, client, filename, metadata.ChunkNumber, metadata.StoredHash)
+		if getErr != nil {
+			err = fmt.Errorf("Error getting chunk from %s: %v", serviceName, getErr)
+			fmt.Printf("%v, trying next replica\n", err)
+			continue
+		}
+
+		if calculateChunkHash(chunkData) != metadata.StoredHash {
+			err = fmt.Errorf("Chunk hash mismatch for chunk %d on %s", metadata.ChunkNumber, serviceName)
+			fmt.Printf("%v, trying next replica\n", err)
+			continue
+		}
+
+		return chunkData, nil
+	}
+
+	return nil, err
+}
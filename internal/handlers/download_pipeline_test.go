@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	filetransfer "s3-example/api/gen/go"
+	"s3-example/internal/clients"
+	"s3-example/internal/compression"
+	"s3-example/internal/storage"
+
+	"google.golang.org/grpc"
+)
+
+// fakeChunkNode serves chunks by their stored hash. If release holds a
+// channel for a chunk, GetChunk waits until it is closed.
+type fakeChunkNode struct {
+	filetransfer.FileTransferServiceClient
+
+	chunks  map[string][]byte
+	release map[string]chan struct{}
+	delay   map[string]time.Duration
+	err     error
+	started atomic.Int32
+}
+
+func (n *fakeChunkNode) GetChunk(ctx context.Context, request *filetransfer.ChunkRequest, opts ...grpc.CallOption) (*filetransfer.ChunkResponse, error) {
+	n.started.Add(1)
+	if release := n.release[request.ChunkHash]; release != nil {
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	time.Sleep(n.delay[request.ChunkHash])
+	if n.err != nil {
+		return nil, n.err
+	}
+	return &filetransfer.ChunkResponse{Chunk: n.chunks[request.ChunkHash]}, nil
+}
+
+// testChunkSet returns count chunks stored in plain on node "a".
+func testChunkSet(count int) ([]storage.ChunkMetadata, map[string][]byte) {
+	chunks := make([]storage.ChunkMetadata, count)
+	data := make(map[string][]byte, count)
+	for i := range chunks {
+		chunkData := []byte(fmt.Sprintf("chunk %d", i))
+		hash := calculateChunkHash(chunkData)
+		chunks[i] = storage.ChunkMetadata{
+			ChunkNumber:  int32(i),
+			ChunkSize:    int64(len(chunkData)),
+			ChunkHash:    hash,
+			Compression:  compression.None,
+			StoredHash:   hash,
+			StoredSize:   int64(len(chunkData)),
+			Replicas:     []string{"a"},
+			StripeNumber: storage.NoStripe,
+		}
+		data[hash] = chunkData
+	}
+	return chunks, data
+}
+
+func testFileHandler() *FileHandler {
+	return &FileHandler{grpcClientManager: clients.NewGrpcClientManager(nil)}
+}
+
+func TestFetchChunksOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		window int
+	}{
+		{name: "window of one", window: 1},
+		{name: "window of three", window: 3},
+		{name: "window larger than the object", window: 20},
+		{name: "no window", window: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, data := testChunkSet(8)
+			// Later chunks arrive first.
+			node := &fakeChunkNode{chunks: data, delay: make(map[string]time.Duration)}
+			for i, chunk := range chunks {
+				node.delay[chunk.StoredHash] = time.Duration(len(chunks)-i) * time.Millisecond
+			}
+			grpcClients := map[string]filetransfer.FileTransferServiceClient{"a": node}
+
+			got := 0
+			for slot := range testFileHandler().fetchChunks(context.Background(), "", nil, chunks, grpcClients, tt.window) {
+				res := <-slot
+				if res.err != nil {
+					t.Fatalf("chunk %d: %v", got, res.err)
+				}
+				if res.chunkNumber != int32(got) || !bytes.Equal(res.data, data[chunks[got].StoredHash]) {
+					t.Fatalf("result %d is chunk %d (%q), want chunk %d", got, res.chunkNumber, res.data, got)
+				}
+				got++
+			}
+			if got != len(chunks) {
+				t.Errorf("got %d chunks, want %d", got, len(chunks))
+			}
+		})
+	}
+}
+
+func TestFetchChunksWindow(t *testing.T) {
+	for _, window := range []int{1, 2, 4} {
+		t.Run(fmt.Sprintf("window of %d", window), func(t *testing.T) {
+			chunks, data := testChunkSet(10)
+			node := &fakeChunkNode{chunks: data, release: make(map[string]chan struct{})}
+			for _, chunk := range chunks {
+				node.release[chunk.StoredHash] = make(chan struct{})
+			}
+			grpcClients := map[string]filetransfer.FileTransferServiceClient{"a": node}
+
+			consumed := 0
+			for slot := range testFileHandler().fetchChunks(context.Background(), "", nil, chunks, grpcClients, window) {
+				// Give the prefetcher time to run ahead as far as it may.
+				time.Sleep(10 * time.Millisecond)
+				if started := int(node.started.Load()); started > consumed+window {
+					t.Fatalf("%d chunks fetched with %d consumed, want at most %d ahead", started, consumed, window)
+				}
+
+				close(node.release[chunks[consumed].StoredHash])
+				if res := <-slot; res.err != nil {
+					t.Fatal(res.err)
+				}
+				consumed++
+			}
+			if consumed != len(chunks) {
+				t.Errorf("consumed %d chunks, want %d", consumed, len(chunks))
+			}
+		})
+	}
+}
+
+func TestFetchChunksErrors(t *testing.T) {
+	errNode := errors.New("node unavailable")
+
+	tests := []struct {
+		name string
+		// nodes maps node names to the chunks they serve; corrupt nodes
+		// serve other bytes.
+		nodes    map[string]string
+		replicas []string
+		wantErr  bool
+	}{
+		{name: "first replica", nodes: map[string]string{"a": "good"}, replicas: []string{"a", "b"}},
+		{name: "second replica", nodes: map[string]string{"a": "failing", "b": "good"}, replicas: []string{"a", "b"}},
+		{name: "first replica corrupt", nodes: map[string]string{"a": "corrupt", "b": "good"}, replicas: []string{"a", "b"}},
+		{name: "unknown node", nodes: map[string]string{"b": "good"}, replicas: []string{"a", "b"}},
+		{name: "all replicas fail", nodes: map[string]string{"a": "failing", "b": "corrupt"}, replicas: []string{"a", "b"}, wantErr: true},
+		{name: "no replicas", nodes: map[string]string{"a": "good"}, replicas: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, data := testChunkSet(5)
+			corrupt := make(map[string][]byte, len(data))
+			for hash, chunkData := range data {
+				corrupt[hash] = append([]byte("bad "), chunkData...)
+			}
+
+			grpcClients := make(map[string]filetransfer.FileTransferServiceClient)
+			for name, kind := range tt.nodes {
+				switch kind {
+				case "good":
+					grpcClients[name] = &fakeChunkNode{chunks: data}
+				case "corrupt":
+					grpcClients[name] = &fakeChunkNode{chunks: corrupt}
+				case "failing":
+					grpcClients[name] = &fakeChunkNode{err: errNode}
+				}
+			}
+
+			// Only the middle chunk is read from the nodes of the test case.
+			grpcClients["other"] = &fakeChunkNode{chunks: data}
+			for i := range chunks {
+				chunks[i].Replicas = []string{"other"}
+			}
+			chunks[2].Replicas = tt.replicas
+
+			var errs []int32
+			count := 0
+			for slot := range testFileHandler().fetchChunks(context.Background(), "", nil, chunks, grpcClients, 2) {
+				res := <-slot
+				if res.err != nil {
+					errs = append(errs, res.chunkNumber)
+				} else if !bytes.Equal(res.data, data[chunks[count].StoredHash]) {
+					t.Errorf("chunk %d = %q, want %q", count, res.data, data[chunks[count].StoredHash])
+				}
+				count++
+			}
+
+			if count != len(chunks) {
+				t.Errorf("got %d results, want %d", count, len(chunks))
+			}
+			var wantErrs []int32
+			if tt.wantErr {
+				wantErrs = []int32{2}
+			}
+			if !slices.Equal(errs, wantErrs) {
+				t.Errorf("chunks %v failed, want %v", errs, wantErrs)
+			}
+		})
+	}
+}
+
+func TestFetchChunksCancel(t *testing.T) {
+	chunks, data := testChunkSet(10)
+	node := &fakeChunkNode{chunks: data, release: make(map[string]chan struct{})}
+	for _, chunk := range chunks[1:] {
+		node.release[chunk.StoredHash] = make(chan struct{})
+	}
+	grpcClients := map[string]filetransfer.FileTransferServiceClient{"a": node}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slots := testFileHandler().fetchChunks(ctx, "", nil, chunks, grpcClients, 3)
+
+	if res := <-<-slots; res.err != nil {
+		t.Fatal(res.err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if started := int(node.started.Load()); started > 1+3 {
+		t.Errorf("%d chunks fetched with one consumed, want at most 1+3", started)
+	}
+	cancel()
+
+	// The slots handed out get the cancellation, and the channel is closed
+	// without waiting for the rest of the chunks.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for slot := range slots {
+			wg.Add(1)
+			go func(slot chan chunkResult) {
+				defer wg.Done()
+				if res := <-slot; res.err == nil {
+					t.Errorf("chunk %d fetched after the download was cancelled", res.chunkNumber)
+				}
+			}(slot)
+		}
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("fetchChunks kept going after the download was cancelled")
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"s3-example/internal/sse"
+	"s3-example/internal/storage"
+)
+
+const (
+	sseHeader                  = "X-Amz-Server-Side-Encryption"
+	sseCustomerAlgorithmHeader = "X-Amz-Server-Side-Encryption-Customer-Algorithm"
+	sseCustomerKeyHeader       = "X-Amz-Server-Side-Encryption-Customer-Key"
+	sseCustomerKeyMD5Header    = "X-Amz-Server-Side-Encryption-Customer-Key-Md5"
+
+	sseAlgorithm = "AES256"
+)
+
+var (
+	errEncryptionUnavailable = errors.New("Server-side encryption is not configured")
+	errUnsupportedEncryption = errors.New("Only AES256 server-side encryption is supported")
+	errInvalidCustomerKey    = errors.New("The SSE-C headers must specify the AES256 algorithm and a base64-encoded 256-bit key with its MD5")
+	errCustomerKeyRequired   = errors.New("The object was stored using a customer-provided key, which is required to access it")
+	errCustomerKeyMismatch   = errors.New("The provided customer key does not match the key the object was stored with")
+)
+
+// encryptionErrorStatus maps errors of newDataKey and openDataKey to HTTP
+// status codes.
+func encryptionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errCustomerKeyMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, errEncryptionUnavailable), errors.Is(err, errUnsupportedEncryption),
+		errors.Is(err, errInvalidCustomerKey), errors.Is(err, errCustomerKeyRequired):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// customerKey returns the key a client supplied in the SSE-C headers, or
+// nil if the request carries none.
+func customerKey(r *http.Request) ([]byte, error) {
+	algorithm := r.Header.Get(sseCustomerAlgorithmHeader)
+	encodedKey := r.Header.Get(sseCustomerKeyHeader)
+	keyMD5 := r.Header.Get(sseCustomerKeyMD5Header)
+	if algorithm == "" && encodedKey == "" && keyMD5 == "" {
+		return nil, nil
+	}
+	if algorithm != sseAlgorithm {
+		return nil, errInvalidCustomerKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != sse.KeySize {
+		return nil, errInvalidCustomerKey
+	}
+	sum := md5.Sum(key)
+	if keyMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, errInvalidCustomerKey
+	}
+	return key, nil
+}
+
+// newDataKey creates the data key of a new object and returns it both
+// wrapped, for storing, and in plain, for encrypting the object's chunks.
+// The key is wrapped with the client's key if the request carries SSE-C
+// headers, else with the KMS master key if one is configured. Without
+// either, the object is stored in plain and the returned key is nil.
+func (h *FileHandler) newDataKey(r *http.Request) (storage.DataKey, []byte, error) {
+	clientKey, err := customerKey(r)
+	if err != nil {
+		return storage.DataKey{}, nil, err
+	}
+
+	requested := r.Header.Get(sseHeader)
+	if requested != "" && requested != sseAlgorithm && requested != "aws:kms" {
+		return storage.DataKey{}, nil, errUnsupportedEncryption
+	}
+	if clientKey == nil && h.kms == nil {
+		if requested != "" {
+			return storage.DataKey{}, nil, errEncryptionUnavailable
+		}
+		return storage.DataKey{Encryption: sse.ModeNone}, nil, nil
+	}
+
+	key, err := sse.NewDataKey()
+	if err != nil {
+		return storage.DataKey{}, nil, err
+	}
+
+	if clientKey != nil {
+		wrapped, err := sse.Seal(clientKey, key, nil)
+		if err != nil {
+			return storage.DataKey{}, nil, err
+		}
+		return storage.DataKey{Encryption: sse.ModeCustomer, Wrapped: wrapped}, key, nil
+	}
+
+	masterKeyID, wrapped, err := h.kms.Wrap(key)
+	if err != nil {
+		return storage.DataKey{}, nil, fmt.Errorf("Error wrapping data key: %v", err)
+	}
+	return storage.DataKey{Encryption: sse.ModeKMS, MasterKeyID: masterKeyID, Wrapped: wrapped}, key, nil
+}
+
+// openDataKey unwraps the data key of an existing object or multipart
+// upload. It returns nil for objects stored in plain.
+func (h *FileHandler) openDataKey(r *http.Request, dataKey storage.DataKey) ([]byte, error) {
+	switch dataKey.Encryption {
+	case sse.ModeNone, "":
+		return nil, nil
+	case sse.ModeKMS:
+		if h.kms == nil {
+			return nil, fmt.Errorf("Object is encrypted with master key %q, but no KMS is configured", dataKey.MasterKeyID)
+		}
+		key, err := h.kms.Unwrap(dataKey.MasterKeyID, dataKey.Wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("Error unwrapping data key: %v", err)
+		}
+		return key, nil
+	case sse.ModeCustomer:
+		clientKey, err := customerKey(r)
+		if err != nil {
+			return nil, err
+		}
+		if clientKey == nil {
+			return nil, errCustomerKeyRequired
+		}
+		key, err := sse.Open(clientKey, dataKey.Wrapped, nil)
+		if err != nil {
+			return nil, errCustomerKeyMismatch
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("Unknown encryption %q", dataKey.Encryption)
+	}
+}
+
+// setEncryptionHeaders tells the client how the object is encrypted, the
+// way S3 does.
+func setEncryptionHeaders(w http.ResponseWriter, r *http.Request, dataKey storage.DataKey) {
+	switch dataKey.Encryption {
+	case sse.ModeKMS:
+		w.Header().Set(sseHeader, sseAlgorithm)
+	case sse.ModeCustomer:
+		w.Header().Set(sseCustomerAlgorithmHeader, sseAlgorithm)
+		w.Header().Set(sseCustomerKeyMD5Header, r.Header.Get(sseCustomerKeyMD5Header))
+	}
+}
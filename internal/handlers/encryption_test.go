@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCustomerKey(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	sum := md5.Sum(key)
+	keyMD5 := base64.StdEncoding.EncodeToString(sum[:])
+
+	shortKey := make([]byte, 16)
+	shortSum := md5.Sum(shortKey)
+
+	tests := []struct {
+		name      string
+		algorithm string
+		key       string
+		keyMD5    string
+		want      []byte
+		wantErr   error
+	}{
+		{name: "no headers"},
+		{name: "valid", algorithm: "AES256", key: encodedKey, keyMD5: keyMD5, want: key},
+		{name: "wrong algorithm", algorithm: "AES128", key: encodedKey, keyMD5: keyMD5, wantErr: errInvalidCustomerKey},
+		{name: "missing algorithm", key: encodedKey, keyMD5: keyMD5, wantErr: errInvalidCustomerKey},
+		{name: "missing key", algorithm: "AES256", keyMD5: keyMD5, wantErr: errInvalidCustomerKey},
+		{name: "missing MD5", algorithm: "AES256", key: encodedKey, wantErr: errInvalidCustomerKey},
+		{name: "MD5 mismatch", algorithm: "AES256", key: encodedKey, keyMD5: base64.StdEncoding.EncodeToString(shortSum[:]), wantErr: errInvalidCustomerKey},
+		{name: "key not base64", algorithm: "AES256", key: "not*base64", keyMD5: keyMD5, wantErr: errInvalidCustomerKey},
+		{
+			name:      "key too short",
+			algorithm: "AES256",
+			key:       base64.StdEncoding.EncodeToString(shortKey),
+			keyMD5:    base64.StdEncoding.EncodeToString(shortSum[:]),
+			wantErr:   errInvalidCustomerKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/bucket/key", nil)
+			if tt.algorithm != "" {
+				r.Header.Set(sseCustomerAlgorithmHeader, tt.algorithm)
+			}
+			if tt.key != "" {
+				r.Header.Set(sseCustomerKeyHeader, tt.key)
+			}
+			if tt.keyMD5 != "" {
+				r.Header.Set(sseCustomerKeyMD5Header, tt.keyMD5)
+			}
+
+			got, err := customerKey(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("customerKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("customerKey() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
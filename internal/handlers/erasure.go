@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	filetransfer "s3-example/api/gen/go"
+	"s3-example/internal/compression"
+	"s3-example/internal/storage"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// stripe collects the data chunks of one erasure-coded stripe until its
+// parity can be computed. Parity is computed over the chunks as stored,
+// compressed and encrypted, so that a lost shard can be rebuilt without
+// the object's key. nodes holds a distinct node for every shard, placed by
+// the hash of the stripe's first data chunk.
+type stripe struct {
+	number int32
+	nodes  []string
+	data   [][]byte
+}
+
+// shardReplicas returns the node of the shard with the given index. Parity
+// shards follow the data shards, so in a short last stripe they take the
+// nodes of the data shards the stripe lacks.
+func (s *stripe) shardReplicas(shardIndex int32) []string {
+	return s.nodes[shardIndex : shardIndex+1]
+}
+
+// padShard returns data extended with zeroes to size. The chunks of a
+// stripe differ in length, but all shards of a stripe must be equally long.
+func padShard(data []byte, size int64) []byte {
+	if int64(len(data)) >= size {
+		return data
+	}
+	padded := make([]byte, size)
+	copy(padded, data)
+	return padded
+}
+
+// encodeParity pads the data shards to the longest of them and computes
+// the parity shards. It returns all shards, data first, and their size.
+func encodeParity(data [][]byte, parityShards int) ([][]byte, int64, error) {
+	shardSize := int64(0)
+	for _, shard := range data {
+		shardSize = max(shardSize, int64(len(shard)))
+	}
+
+	shards := make([][]byte, len(data)+parityShards)
+	for i, shard := range data {
+		shards[i] = padShard(shard, shardSize)
+	}
+	for i := len(data); i < len(shards); i++ {
+		shards[i] = make([]byte, shardSize)
+	}
+
+	enc, err := reedsolomon.New(len(data), parityShards)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := enc.Encode(shards); err != nil {
+		return nil, 0, err
+	}
+	return shards, shardSize, nil
+}
+
+// decodeShard rebuilds the shard with the given index from the others and
+// returns it cut to size. Missing shards are nil; at least dataShards of
+// them must be present.
+func decodeShard(shards [][]byte, dataShards int, index int32, size int64) ([]byte, error) {
+	enc, err := reedsolomon.New(dataShards, len(shards)-dataShards)
+	if err != nil {
+		return nil, err
+	}
+	if int(index) >= dataShards {
+		err = enc.Reconstruct(shards)
+	} else {
+		err = enc.ReconstructData(shards)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return shards[index][:size], nil
+}
+
+// sendParity computes the parity chunks of a stripe and sends each of them
+// to its own node.
+func (h *FileHandler) sendParity(sink *chunkSink, owner storage.ChunkMetadata, filename string, s *stripe) error {
+	shards, shardSize, err := encodeParity(s.data, h.cfg.ECParityShards)
+	if err != nil {
+		return fmt.Errorf("Error encoding stripe %d: %v", s.number, err)
+	}
+
+	for i := len(s.data); i < len(shards); i++ {
+		if err := sink.Acquire(); err != nil {
+			return err
+		}
+
+		// Parity of stored data reveals nothing that needs encrypting and
+		// hardly compresses, so it is stored as it is.
+		chunkHash := calculateChunkHash(shards[i])
+		metadata := storage.ChunkMetadata{
+			FileID:       owner.FileID,
+			PartID:       owner.PartID,
+			ChunkNumber:  s.number,
+			ChunkSize:    shardSize,
+			ChunkHash:    chunkHash,
+			Compression:  compression.None,
+			StoredHash:   chunkHash,
+			StoredSize:   shardSize,
+			Replicas:     s.shardReplicas(int32(i)),
+			StripeNumber: s.number,
+			ShardIndex:   int32(i),
+			IsParity:     true,
+		}
+
+		stored, err := h.dbManager.SaveChunkMetadata(metadata)
+		if err != nil {
+			sink.Release()
+			return fmt.Errorf("Error saving chunk metadata: %v", err)
+		}
+
+		err = sink.Send(missingReplicas(metadata.Replicas, stored), &filetransfer.FileChunk{
+			Filename:    filename,
+			Chunk:       shards[i],
+			ChunkNumber: s.number,
+			ChunkHash:   metadata.StoredHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	s.data = nil
+	return nil
+}
+
+// reconstructChunk rebuilds the stored bytes of an unreadable chunk from
+// any K readable shards of its stripe.
+func (h *FileHandler) reconstructChunk(ctx context.Context, filename string, metadata storage.ChunkMetadata, grpcClients map[string]filetransfer.FileTransferServiceClient) ([]byte, error) {
+	members, err := h.dbManager.GetStripeChunkMetadata(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting stripe metadata: %v", err)
+	}
+
+	dataShards := 0
+	shardSize := int64(0)
+	for _, member := range members {
+		if !member.IsParity {
+			dataShards++
+		}
+		shardSize = max(shardSize, member.StoredSize)
+	}
+
+	shards := make([][]byte, len(members))
+	available := 0
+	for _, member := range members {
+		if available == dataShards {
+			break
+		}
+		if member.ShardIndex == metadata.ShardIndex || int(member.ShardIndex) >= len(shards) {
+			continue
+		}
+
+		data, err := h.readStoredChunk(ctx, filename, member, grpcClients)
+		if err != nil {
+			continue
+		}
+		shards[member.ShardIndex] = padShard(data, shardSize)
+		available++
+	}
+	if available < dataShards {
+		return nil, fmt.Errorf("Cannot reconstruct chunk %d: %d of %d shards available", metadata.ChunkNumber, available, dataShards)
+	}
+
+	data, err := decodeShard(shards, dataShards, metadata.ShardIndex, metadata.StoredSize)
+	if err != nil {
+		return nil, fmt.Errorf("Error reconstructing chunk %d: %v", metadata.ChunkNumber, err)
+	}
+	if calculateChunkHash(data) != metadata.StoredHash {
+		return nil, fmt.Errorf("Reconstructed chunk %d does not match its hash", metadata.ChunkNumber)
+	}
+	return data, nil
+}
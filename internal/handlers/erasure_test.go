@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"math/bits"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestStripeShardReplicas(t *testing.T) {
+	s := &stripe{nodes: []string{"a", "b", "c", "d", "e", "f"}}
+
+	tests := []struct {
+		name       string
+		dataShards int
+		want       []string
+	}{
+		{name: "full stripe", dataShards: 4, want: []string{"a", "b", "c", "d", "e", "f"}},
+		{name: "short last stripe", dataShards: 2, want: []string{"a", "b", "c", "d"}},
+		{name: "single chunk", dataShards: 1, want: []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Two parity shards follow the data shards of the stripe.
+			var got []string
+			for i := 0; i < tt.dataShards+2; i++ {
+				got = append(got, s.shardReplicas(int32(i))...)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("shards placed on %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeParity(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	chunk := func(n int) []byte {
+		data := make([]byte, n)
+		rng.Read(data)
+		return data
+	}
+
+	tests := []struct {
+		name         string
+		data         [][]byte
+		parityShards int
+	}{
+		{name: "full stripe", data: [][]byte{chunk(1000), chunk(1000), chunk(1000), chunk(1000)}, parityShards: 2},
+		{name: "short last chunk", data: [][]byte{chunk(1000), chunk(1000), chunk(1000), chunk(10)}, parityShards: 2},
+		{name: "short last stripe", data: [][]byte{chunk(700), chunk(300)}, parityShards: 2},
+		{name: "single chunk", data: [][]byte{chunk(500)}, parityShards: 3},
+		{name: "uneven chunks", data: [][]byte{chunk(100), chunk(900), chunk(1), chunk(450)}, parityShards: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards, shardSize, err := encodeParity(tt.data, tt.parityShards)
+			if err != nil {
+				t.Fatalf("encodeParity() error = %v", err)
+			}
+			if len(shards) != len(tt.data)+tt.parityShards {
+				t.Fatalf("encodeParity() returned %d shards, want %d", len(shards), len(tt.data)+tt.parityShards)
+			}
+
+			longest := 0
+			for _, data := range tt.data {
+				longest = max(longest, len(data))
+			}
+			if shardSize != int64(longest) {
+				t.Fatalf("shard size = %d, want %d", shardSize, longest)
+			}
+			for i, shard := range shards {
+				if int64(len(shard)) != shardSize {
+					t.Fatalf("shard %d is %d bytes, want %d", i, len(shard), shardSize)
+				}
+			}
+			for i, data := range tt.data {
+				padding := shards[i][len(data):]
+				if !bytes.Equal(shards[i][:len(data)], data) || !bytes.Equal(padding, make([]byte, len(padding))) {
+					t.Fatalf("data shard %d is not the chunk padded with zeroes", i)
+				}
+			}
+
+			// Every shard comes back unchanged as long as no more than
+			// parityShards are lost, whichever they are.
+			sizes := make([]int64, len(shards))
+			for i := range shards {
+				sizes[i] = shardSize
+				if i < len(tt.data) {
+					sizes[i] = int64(len(tt.data[i]))
+				}
+			}
+			for lost := 1; lost < 1<<len(shards); lost++ {
+				dropped := bits.OnesCount(uint(lost))
+				if dropped > tt.parityShards {
+					continue
+				}
+				for i := range shards {
+					if lost&(1<<i) == 0 {
+						continue
+					}
+
+					got, err := decodeShard(withoutShards(shards, lost), len(tt.data), int32(i), sizes[i])
+					if err != nil {
+						t.Fatalf("decodeShard(%d) with %d shards lost: error = %v", i, dropped, err)
+					}
+					if !bytes.Equal(got, shards[i][:sizes[i]]) {
+						t.Fatalf("decodeShard(%d) with %d shards lost returned other bytes", i, dropped)
+					}
+				}
+			}
+
+			tooMany := 1<<(tt.parityShards+1) - 1
+			if _, err := decodeShard(withoutShards(shards, tooMany), len(tt.data), 0, sizes[0]); err == nil {
+				t.Errorf("decodeShard() rebuilt a shard with %d shards lost", tt.parityShards+1)
+			}
+		})
+	}
+}
+
+// withoutShards copies the shards, leaving out those whose bit is set in
+// lost.
+func withoutShards(shards [][]byte, lost int) [][]byte {
+	kept := make([][]byte, len(shards))
+	for i, shard := range shards {
+		if lost&(1<<i) == 0 {
+			kept[i] = bytes.Clone(shard)
+		}
+	}
+	return kept
+}
@@ -1,296 +1,608 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
-	"sync"
+	"path"
+	"slices"
 
 	filetransfer "s3-example/api/gen/go"
+	"s3-example/internal/chunker"
 	"s3-example/internal/clients"
+	"s3-example/internal/compression"
 	"s3-example/internal/config"
+	"s3-example/internal/placement"
+	"s3-example/internal/sse"
 	"s3-example/internal/storage"
 )
 
+const (
+	chunkingHeader  = "X-Chunking"
+	chunkingSetting = "chunking"
+)
+
+var (
+	errObjectNotFound = errors.New("File not found")
+	errBucketNotFound = errors.New("Bucket not found")
+)
+
 type FileHandler struct {
 	cfg               *config.TransferServiceConfig
 	grpcClientManager *clients.GrpcClientManager
 	dbManager         *storage.Manager
+	placement         placement.Placement
+	// kms wraps the data keys of new objects. It is nil when no master key
+	// is configured; objects are then only encrypted with SSE-C.
+	kms sse.KMS
 }
 
-func NewFileHandler(cfg *config.TransferServiceConfig, grpcClientManager *clients.GrpcClientManager, dbManager *storage.Manager) *FileHandler {
+func NewFileHandler(cfg *config.TransferServiceConfig, grpcClientManager *clients.GrpcClientManager, dbManager *storage.Manager, chunkPlacement placement.Placement, kms sse.KMS) *FileHandler {
 	return &FileHandler{
 		cfg:               cfg,
 		grpcClientManager: grpcClientManager,
 		dbManager:         dbManager,
+		placement:         chunkPlacement,
+		kms:               kms,
 	}
 }
 
+// uploadOptions controls how the data of an upload is stored.
+type uploadOptions struct {
+	chunking string
+	// dataKey is the wrapped data key of the object and key the same key
+	// in plain, or nil if the object is stored unencrypted.
+	dataKey storage.DataKey
+	key     []byte
+}
+
 func calculateChunkHash(data []byte) string {
 	hash := sha256.Sum256(data)
 	return hex.EncodeToString(hash[:])
 }
 
 func (h *FileHandler) UploadHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, err := h.bucketFromRequest(r)
+	if errors.Is(err, errBucketNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	opts := uploadOptions{}
+	opts.chunking, err = h.chunkingMode(r.Header.Get(chunkingHeader), bucket)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts.dataKey, opts.key, err = h.newDataKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), encryptionErrorStatus(err))
+		return
+	}
+
 	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxUploadSize)
 
-	err := r.ParseMultipartForm(h.cfg.MaxUploadSize)
+	reader, err := r.MultipartReader()
 	if err != nil {
 		http.Error(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	file, handler, err := r.FormFile("file")
+	file, err := nextFilePart(reader, "file")
 	if err != nil {
 		http.Error(w, "Failed to get file: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
 
-	grpcClients := h.grpcClientManager.GetClients()
-	clientCount := int32(len(grpcClients))
-	if clientCount == 0 {
-		http.Error(w, "No available gRPC connections", http.StatusInternalServerError)
+	contentType := objectContentType(file.Header.Get("Content-Type"), file.FileName())
+	fileMetadata, err := h.storeObject(r.Context(), bucket.ID, file.FileName(), contentType, opts, file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	setEncryptionHeaders(w, r, opts.dataKey)
 
-	bufferSize := h.cfg.ChunkSize
-	buffer := make([]byte, bufferSize)
-	chunkNumber := int32(0)
+	fmt.Printf("Upload completed. Total chunks: %d\n", fileMetadata.TotalChunks)
 
-	totalChunks := int32(0)
-	totalSize := int64(0)
-	contentLength := r.ContentLength
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("File successfully uploaded and sent via gRPC"))
+}
 
-	if contentLength > 0 {
-		totalChunks = int32((contentLength + int64(bufferSize) - 1) / int64(bufferSize))
-		fmt.Printf("Started uploading file '%s' with size %d bytes\n", handler.Filename, contentLength)
+func (h *FileHandler) bucketFromRequest(r *http.Request) (*storage.Bucket, error) {
+	name := r.URL.Query().Get("bucket")
+	if name == "" {
+		name = storage.DefaultBucketName
 	}
 
-	fileID, err := h.dbManager.CreateFileMetadata(handler.Filename, totalChunks, totalSize)
+	bucket, err := h.dbManager.GetBucket(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errBucketNotFound
+	}
 	if err != nil {
-		http.Error(w, "Error adding file to database: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("Error getting bucket: %v", err)
+	}
+	return bucket, nil
+}
+
+// chunkingMode picks the chunking mode of an upload: the one requested by
+// the client, else the bucket's "chunking" setting, else CHUNKING_MODE.
+func (h *FileHandler) chunkingMode(requested string, bucket *storage.Bucket) (string, error) {
+	mode := requested
+	if mode == "" {
+		mode = bucket.Settings[chunkingSetting]
+	}
+	if mode == "" {
+		mode = h.cfg.ChunkingMode
+	}
+	if !chunker.ValidMode(mode) {
+		return "", fmt.Errorf("Unknown chunking mode %q", mode)
 	}
+	return mode, nil
+}
 
-	chunksMap := make(map[string][]*filetransfer.FileChunk)
+func (h *FileHandler) chunkerParams() chunker.Params {
+	return chunker.Params{
+		ChunkSize: h.cfg.ChunkSize,
+		MinSize:   h.cfg.CDCMinSize,
+		AvgSize:   h.cfg.CDCAvgSize,
+		MaxSize:   h.cfg.CDCMaxSize,
+	}
+}
 
-	serviceNames := h.grpcClientManager.GetClientNames()
-	if len(serviceNames) == 0 {
-		http.Error(w, "No available gRPC service names", http.StatusInternalServerError)
-		return
+// storeObject stages the object as a single-part multipart upload and
+// completes it only once all chunks are stored, so an existing object with
+// the same name stays readable until it is replaced in one transaction.
+func (h *FileHandler) storeObject(ctx context.Context, bucketID int64, filename, contentType string, opts uploadOptions, src io.Reader) (*storage.FileMetadata, error) {
+	nodes := h.grpcClientManager.GetNodes()
+	if len(nodes) == 0 {
+		return nil, errors.New("No available gRPC connections")
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	upload := &storage.MultipartUpload{
+		UploadID:    hex.EncodeToString(b),
+		BucketID:    bucketID,
+		Filename:    filename,
+		ContentType: contentType,
+		DataKey:     opts.dataKey,
 	}
 
+	fmt.Printf("Started uploading file '%s'\n", filename)
+
+	var err error
+	upload.ID, err = h.dbManager.CreateMultipartUpload(bucketID, filename, contentType, upload.UploadID, opts.dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error adding file to database: %v", err)
+	}
+	partID, err := h.dbManager.CreatePart(upload.ID, 1)
+	if err != nil {
+		h.dbManager.AbortMultipartUpload(upload.ID)
+		return nil, fmt.Errorf("Error adding file to database: %v", err)
+	}
+
+	totalChunks, totalSize, err := h.uploadChunks(ctx, storage.ChunkMetadata{PartID: partID}, filename, opts, src, nodes)
+	if err != nil {
+		h.dbManager.AbortMultipartUpload(upload.ID)
+		return nil, err
+	}
+
+	part := storage.MultipartPart{ID: partID, UploadID: upload.ID, PartNumber: 1, TotalChunks: totalChunks, TotalSize: totalSize}
+	fileID, etag, err := h.dbManager.CompleteMultipartUpload(upload, []storage.MultipartPart{part})
+	if err != nil {
+		h.dbManager.AbortMultipartUpload(upload.ID)
+		return nil, fmt.Errorf("Error updating file information: %v", err)
+	}
+
+	return &storage.FileMetadata{
+		ID:          fileID,
+		BucketID:    bucketID,
+		Filename:    filename,
+		TotalChunks: totalChunks,
+		TotalSize:   totalSize,
+		ContentType: contentType,
+		ETag:        etag,
+	}, nil
+}
+
+// objectContentType falls back to guessing from the file extension when the
+// client did not send a content type.
+func objectContentType(header, filename string) string {
+	if header != "" {
+		return header
+	}
+	if contentType := mime.TypeByExtension(path.Ext(filename)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+func nextFilePart(reader *multipart.Reader, formName string) (*multipart.Part, error) {
 	for {
-		bytesRead, err := file.Read(buffer)
-		if err != nil && err != io.EOF {
-			http.Error(w, "Error reading file: "+err.Error(), http.StatusInternalServerError)
-			return
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, http.ErrMissingFile
 		}
-		if bytesRead == 0 {
-			break
+		if err != nil {
+			return nil, err
 		}
+		if part.FormName() == formName && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
 
-		chunkData := make([]byte, bytesRead)
-		copy(chunkData, buffer[:bytesRead])
+// storePart uploads the data of one multipart part and returns its ETag.
+func (h *FileHandler) storePart(ctx context.Context, partID int64, filename string, opts uploadOptions, src io.Reader) (string, error) {
+	nodes := h.grpcClientManager.GetNodes()
+	if len(nodes) == 0 {
+		return "", errors.New("No available gRPC connections")
+	}
 
-		totalSize += int64(bytesRead)
+	totalChunks, totalSize, err := h.uploadChunks(ctx, storage.ChunkMetadata{PartID: partID}, filename, opts, src, nodes)
+	if err != nil {
+		return "", err
+	}
 
-		serviceIndex := chunkNumber % clientCount
-		serviceName := serviceNames[serviceIndex]
+	return h.finishPart(partID, totalChunks, totalSize)
+}
 
-		chunkHash := calculateChunkHash(chunkData)
+func (h *FileHandler) finishPart(partID int64, totalChunks int32, totalSize int64) (string, error) {
+	etag, err := h.dbManager.UpdatePart(partID, totalChunks, totalSize)
+	if err != nil {
+		return "", fmt.Errorf("Error updating part information: %v", err)
+	}
 
-		chunk := &filetransfer.FileChunk{
-			Filename:    handler.Filename,
-			Chunk:       chunkData,
-			ChunkNumber: chunkNumber,
-			TotalChunks: totalChunks,
-			ChunkHash:   chunkHash,
-			ServiceName: serviceName,
-		}
-		chunksMap[serviceName] = append(chunksMap[serviceName], chunk)
+	return etag, nil
+}
 
-		err = h.dbManager.SaveChunkMetadata(storage.ChunkMetadata{
-			FileID:      fileID,
-			ChunkNumber: chunkNumber,
-			ServiceName: serviceName,
-			ChunkSize:   int64(bytesRead),
-			ChunkHash:   chunkHash,
-		})
+// uploadChunks splits src into chunks and streams them to the storage nodes.
+// Chunk rows are attached to whatever owner (file or multipart part) is set
+// in owner. If reading src fails, the chunks read before the failure are
+// still delivered and counted, and a *sourceReadError is returned.
+func (h *FileHandler) uploadChunks(ctx context.Context, owner storage.ChunkMetadata, filename string, opts uploadOptions, src io.Reader, nodes []placement.Node) (int32, int64, error) {
+	sink, err := newChunkSink(ctx, h.grpcClientManager, nodes, h.cfg.UploadWindow)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	totalChunks, totalSize, err := h.pumpChunks(sink, owner, filename, src, opts, nodes)
+	var readErr *sourceReadError
+	if err != nil && !errors.As(err, &readErr) {
+		sink.fail(err)
+		sink.Close()
+		return 0, 0, err
+	}
+	if err := sink.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err := h.dbManager.MarkChunkBlobsStored(owner); err != nil {
+		return 0, 0, fmt.Errorf("Error updating chunk blobs: %v", err)
+	}
+
+	return totalChunks, totalSize, err
+}
+
+// encodeChunk compresses the chunk with the configured codec, unless that
+// saves too little, then encrypts it if a data key is given. The codec and
+// the hash and size of the stored bytes are recorded in metadata.
+func (h *FileHandler) encodeChunk(metadata *storage.ChunkMetadata, data, key []byte) ([]byte, error) {
+	storedData, codec, err := compression.Compress(h.cfg.Compression, data)
+	if err != nil {
+		return nil, fmt.Errorf("Error compressing chunk %d: %v", metadata.ChunkNumber, err)
+	}
+	metadata.Compression = codec
+
+	if key != nil {
+		// Binding the ciphertext to the chunk's hash keeps it from being
+		// swapped with another chunk of the object.
+		storedData, err = sse.Seal(key, storedData, []byte(metadata.ChunkHash))
 		if err != nil {
-			http.Error(w, "Error saving chunk metadata: "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("Error encrypting chunk %d: %v", metadata.ChunkNumber, err)
 		}
+		metadata.Encrypted = true
+	}
 
-		chunkNumber++
-		if totalChunks > 0 {
-			percent := float64(chunkNumber) / float64(totalChunks) * 100
-			fmt.Printf("Uploaded %d/%d chunks (%.2f%%)\n", chunkNumber, totalChunks, percent)
-		} else {
-			fmt.Printf("Uploaded %d chunks\n", chunkNumber)
+	metadata.StoredHash = metadata.ChunkHash
+	if codec != compression.None || metadata.Encrypted {
+		metadata.StoredHash = calculateChunkHash(storedData)
+	}
+	metadata.StoredSize = int64(len(storedData))
+	return storedData, nil
+}
+
+// missingReplicas returns the replicas whose node does not store the chunk
+// yet. Identical chunks are only sent once to each node.
+func missingReplicas(replicas, stored []string) []string {
+	if len(stored) == 0 {
+		return replicas
+	}
+
+	missing := make([]string, 0, len(replicas))
+	for _, serviceName := range replicas {
+		if !slices.Contains(stored, serviceName) {
+			missing = append(missing, serviceName)
+		}
+	}
+	return missing
+}
+
+type sourceReadError struct {
+	err error
+}
+
+func (e *sourceReadError) Error() string {
+	return "Error reading file: " + e.err.Error()
+}
+
+func (e *sourceReadError) Unwrap() error {
+	return e.err
+}
+
+// sourceReader remembers the first real read error, which io.ReadFull would
+// otherwise report as a clean short read.
+type sourceReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+func (h *FileHandler) pumpChunks(sink *chunkSink, owner storage.ChunkMetadata, filename string, src io.Reader, opts uploadOptions, nodes []placement.Node) (int32, int64, error) {
+	clientCount := int32(len(nodes))
+	replicationFactor := max(int32(h.cfg.ReplicationFactor), 1)
+	dataShards := int32(h.cfg.ECDataShards)
+
+	var current *stripe
+	if h.cfg.RedundancyMode == config.RedundancyErasure {
+		if width := dataShards + int32(h.cfg.ECParityShards); width > clientCount {
+			return 0, 0, fmt.Errorf("Not enough storage nodes for %d+%d erasure coding: %d available", dataShards, h.cfg.ECParityShards, clientCount)
 		}
+		current = &stripe{}
+	} else if replicationFactor > clientCount {
+		return 0, 0, fmt.Errorf("Not enough storage nodes for replication factor %d: %d available", replicationFactor, clientCount)
 	}
 
-	err = h.dbManager.UpdateFileMetadata(fileID, chunkNumber, totalSize)
+	chunkNumber := int32(0)
+	totalSize := int64(0)
+	reader := &sourceReader{r: src}
+	chunks, err := chunker.New(opts.chunking, reader, h.chunkerParams())
 	if err != nil {
-		http.Error(w, "Error updating file information: "+err.Error(), http.StatusInternalServerError)
-		return
+		return 0, 0, err
 	}
 
-	fmt.Printf("Upload completed. Total chunks: %d\n", totalChunks)
+	var readErr error
+	for {
+		if err := sink.Acquire(); err != nil {
+			return 0, 0, err
+		}
 
-	var wg sync.WaitGroup
-	errCh := make(chan error, len(chunksMap))
+		chunkData, err := chunks.Next()
+		if reader.err != nil {
+			sink.Release()
+			readErr = &sourceReadError{err: reader.err}
+			break
+		}
+		if err == io.EOF {
+			sink.Release()
+			break
+		}
+		if err != nil {
+			sink.Release()
+			return 0, 0, err
+		}
+
+		totalSize += int64(len(chunkData))
 
-	for serviceName, chunks := range chunksMap {
-		wg.Add(1)
-		go func(serviceName string, chunks []*filetransfer.FileChunk) {
-			defer wg.Done()
-			client := h.grpcClientManager.GetClientByName(serviceName)
-			if client == nil {
-				errCh <- fmt.Errorf("gRPC client not found for service: %s", serviceName)
-				return
+		metadata := storage.ChunkMetadata{
+			FileID:       owner.FileID,
+			PartID:       owner.PartID,
+			ChunkNumber:  chunkNumber,
+			ChunkSize:    int64(len(chunkData)),
+			ChunkHash:    calculateChunkHash(chunkData),
+			StripeNumber: storage.NoStripe,
+		}
+		if current != nil {
+			metadata.StripeNumber = chunkNumber - chunkNumber%dataShards
+			metadata.ShardIndex = chunkNumber % dataShards
+			if metadata.ShardIndex == 0 {
+				current.number = metadata.StripeNumber
+				current.nodes, err = h.placement.Place(metadata.ChunkHash, nodes, int(dataShards)+h.cfg.ECParityShards)
+			}
+			if err == nil {
+				metadata.Replicas = current.shardReplicas(metadata.ShardIndex)
 			}
-			err := h.grpcClientManager.SendChunks(client, chunks)
-			if err != nil {
-				errCh <- fmt.Errorf("Error sending chunks via gRPC: %v", err)
-				return
+		} else {
+			metadata.Replicas, err = h.placement.Place(metadata.ChunkHash, nodes, int(replicationFactor))
+		}
+		if err != nil {
+			sink.Release()
+			return 0, 0, fmt.Errorf("Error placing chunk %d: %v", chunkNumber, err)
+		}
+
+		storedData, err := h.encodeChunk(&metadata, chunkData, opts.key)
+		if err != nil {
+			sink.Release()
+			return 0, 0, err
+		}
+
+		stored, err := h.dbManager.SaveChunkMetadata(metadata)
+		if err != nil {
+			sink.Release()
+			return 0, 0, fmt.Errorf("Error saving chunk metadata: %v", err)
+		}
+
+		err = sink.Send(missingReplicas(metadata.Replicas, stored), &filetransfer.FileChunk{
+			Filename:    filename,
+			Chunk:       storedData,
+			ChunkNumber: chunkNumber,
+			ChunkHash:   metadata.StoredHash,
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if current != nil {
+			current.data = append(current.data, storedData)
+			if int32(len(current.data)) == dataShards {
+				if err := h.sendParity(sink, owner, filename, current); err != nil {
+					return 0, 0, err
+				}
 			}
-		}(serviceName, chunks)
+		}
+
+		chunkNumber++
+		fmt.Printf("Uploaded %d chunks (%d bytes)\n", chunkNumber, totalSize)
 	}
 
-	wg.Wait()
-	close(errCh)
-	if len(errCh) > 0 {
-		err := <-errCh
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if current != nil && len(current.data) > 0 {
+		if err := h.sendParity(sink, owner, filename, current); err != nil {
+			return 0, 0, err
+		}
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("File successfully uploaded and sent via gRPC"))
+	return chunkNumber, totalSize, readErr
 }
 
 func (h *FileHandler) DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	filename := r.URL.Query().Get("filename")
 	if filename == "" {
 		http.Error(w, "Filename not specified", http.StatusBadRequest)
 		return
 	}
 
-	fileMetadata, err := h.dbManager.GetFileMetadata(filename)
+	bucket, err := h.bucketFromRequest(r)
+	if errors.Is(err, errBucketNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error getting file metadata: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	chunkMetadataList, err := h.dbManager.GetChunkMetadata(fileMetadata.ID)
+	fileMetadata, chunkMetadataList, err := h.loadObject(bucket.ID, filename)
+	if errors.Is(err, errObjectNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error getting chunk metadata: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if len(chunkMetadataList) == 0 {
-		http.Error(w, "Chunks for this file not found", http.StatusNotFound)
+	key, err := h.openDataKey(r, fileMetadata.DataKey)
+	if err != nil {
+		http.Error(w, err.Error(), encryptionErrorStatus(err))
 		return
 	}
 
-	grpcClients := h.grpcClientManager.GetClientsByName()
-	if len(grpcClients) == 0 {
-		http.Error(w, "No available gRPC connections", http.StatusInternalServerError)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	setEncryptionHeaders(w, r, fileMetadata.DataKey)
+
+	if err := h.serveObject(w, r, fileMetadata, chunkMetadataList, key); err != nil {
+		fmt.Printf("Download of '%s' aborted: %v\n", filename, err)
+		return
+	}
+	if r.Method == http.MethodHead {
 		return
 	}
 
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	w.Header().Set("Content-Type", "application/octet-stream")
-
-	totalChunks := fileMetadata.TotalChunks
-
-	type chunkResult struct {
-		chunkNumber int32
-		data        []byte
-		err         error
-	}
-
-	results := make([]*chunkResult, totalChunks)
-
-	var wg sync.WaitGroup
-	for i := int32(0); i < totalChunks; i++ {
-		chunkNumber := i
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			var metadata storage.ChunkMetadata
-			found := false
-			for _, meta := range chunkMetadataList {
-				if meta.ChunkNumber == chunkNumber {
-					metadata = meta
-					found = true
-					break
-				}
-			}
-			if !found {
-				results[chunkNumber] = &chunkResult{
-					chunkNumber: chunkNumber,
-					err:         fmt.Errorf("Metadata for chunk not found: %d", chunkNumber),
-				}
-				return
-			}
+	fmt.Printf("File '%s' successfully downloaded\n", filename)
+}
 
-			client := grpcClients[metadata.ServiceName]
-			if client == nil {
-				results[chunkNumber] = &chunkResult{
-					chunkNumber: chunkNumber,
-					err:         fmt.Errorf("gRPC client not found for service: %s", metadata.ServiceName),
-				}
-				return
-			}
+func (h *FileHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-			chunkData, err := h.grpcClientManager.GetChunk(client, filename, metadata.ChunkNumber, metadata.ChunkHash)
-			if err != nil {
-				results[chunkNumber] = &chunkResult{
-					chunkNumber: chunkNumber,
-					err:         fmt.Errorf("Error getting chunk: %v", err),
-				}
-				return
-			}
+	filename := r.URL.Query().Get("filename")
+	if filename == "" {
+		http.Error(w, "Filename not specified", http.StatusBadRequest)
+		return
+	}
 
-			chunkHash := calculateChunkHash(chunkData)
-			if chunkHash != metadata.ChunkHash {
-				results[chunkNumber] = &chunkResult{
-					chunkNumber: chunkNumber,
-					err:         fmt.Errorf("Chunk hash mismatch for chunk %d", chunkNumber),
-				}
-				return
-			}
+	bucket, err := h.bucketFromRequest(r)
+	if errors.Is(err, errBucketNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-			results[chunkNumber] = &chunkResult{
-				chunkNumber: chunkNumber,
-				data:        chunkData,
-				err:         nil,
-			}
-		}()
+	fileMetadata, err := h.dbManager.GetFileMetadata(bucket.ID, filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errObjectNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error getting file metadata: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	wg.Wait()
+	if err := h.dbManager.DeleteFileMetadata(fileMetadata.ID); err != nil {
+		http.Error(w, "Error deleting file metadata: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	for _, res := range results {
-		if res.err != nil {
-			http.Error(w, res.err.Error(), http.StatusInternalServerError)
-			return
-		}
+	fmt.Printf("File '%s' deleted, %d chunks queued for purging\n", filename, fileMetadata.TotalChunks)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("File successfully deleted"))
+}
+
+func (h *FileHandler) loadObject(bucketID int64, filename string) (*storage.FileMetadata, []storage.ChunkMetadata, error) {
+	fileMetadata, err := h.dbManager.GetFileMetadata(bucketID, filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, errObjectNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting file metadata: %v", err)
 	}
 
-	for _, res := range results {
-		_, err := w.Write(res.data)
-		if err != nil {
-			http.Error(w, "Error sending chunk: "+err.Error(), http.StatusInternalServerError)
-			return
+	chunkMetadataList, err := h.dbManager.GetChunkMetadata(fileMetadata.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting chunk metadata: %v", err)
+	}
+
+	for i, metadata := range chunkMetadataList {
+		if metadata.ChunkNumber != int32(i) {
+			return nil, nil, fmt.Errorf("Metadata for chunk not found: %d", i)
 		}
 	}
+	if int32(len(chunkMetadataList)) != fileMetadata.TotalChunks {
+		return nil, nil, fmt.Errorf("Metadata for chunk not found: %d", len(chunkMetadataList))
+	}
 
-	fmt.Printf("File '%s' successfully downloaded\n", filename)
+	return fileMetadata, chunkMetadataList, nil
 }
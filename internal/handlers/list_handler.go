@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type fileInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Chunks    int32     `json:"chunks"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type listResponse struct {
+	Bucket                string     `json:"bucket"`
+	Prefix                string     `json:"prefix"`
+	Delimiter             string     `json:"delimiter,omitempty"`
+	MaxKeys               int        `json:"max_keys"`
+	IsTruncated           bool       `json:"is_truncated"`
+	NextContinuationToken string     `json:"next_continuation_token,omitempty"`
+	Files                 []fileInfo `json:"files"`
+	CommonPrefixes        []string   `json:"common_prefixes"`
+}
+
+// ListHandler lists the files of a bucket. Keys containing the delimiter
+// after the prefix are grouped into common prefixes, like folders.
+func (h *FileHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	bucket, err := h.bucketFromRequest(r)
+	if errors.Is(err, errBucketNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+
+	maxKeys := defaultMaxKeys
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid max-keys", http.StatusBadRequest)
+			return
+		}
+		maxKeys = min(n, defaultMaxKeys)
+	}
+
+	marker := ""
+	if token := query.Get("continuation-token"); token != "" {
+		decoded, err := base64.URLEncoding.DecodeString(token)
+		if err != nil {
+			http.Error(w, "Invalid continuation-token", http.StatusBadRequest)
+			return
+		}
+		marker = string(decoded)
+	}
+
+	listing, err := h.dbManager.ListObjects(bucket.ID, prefix, delimiter, marker, maxKeys)
+	if err != nil {
+		http.Error(w, "Error listing files: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := listResponse{
+		Bucket:         bucket.Name,
+		Prefix:         prefix,
+		Delimiter:      delimiter,
+		MaxKeys:        maxKeys,
+		IsTruncated:    listing.IsTruncated,
+		Files:          make([]fileInfo, 0, len(listing.Files)),
+		CommonPrefixes: make([]string, 0, len(listing.CommonPrefixes)),
+	}
+	if listing.IsTruncated {
+		result.NextContinuationToken = base64.URLEncoding.EncodeToString([]byte(listing.NextMarker))
+	}
+	for _, file := range listing.Files {
+		result.Files = append(result.Files, fileInfo{
+			Name:      file.Filename,
+			Size:      file.TotalSize,
+			Chunks:    file.TotalChunks,
+			CreatedAt: file.CreatedAt,
+			UpdatedAt: file.UpdatedAt,
+		})
+	}
+	result.CommonPrefixes = append(result.CommonPrefixes, listing.CommonPrefixes...)
+
+	response, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "Error forming response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+type statResponse struct {
+	Bucket       string    `json:"bucket"`
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	Chunks       int32     `json:"chunks"`
+	ETag         string    `json:"etag"`
+	ContentType  string    `json:"content_type"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// StatHandler describes a single file from its metadata without contacting
+// the storage nodes.
+func (h *FileHandler) StatHandler(w http.ResponseWriter, r *http.Request) {
+	filename := r.URL.Query().Get("filename")
+	if filename == "" {
+		http.Error(w, "Filename not specified", http.StatusBadRequest)
+		return
+	}
+
+	bucket, err := h.bucketFromRequest(r)
+	if errors.Is(err, errBucketNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fileMetadata, _, err := h.loadObject(bucket.ID, filename)
+	if errors.Is(err, errObjectNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := json.Marshal(statResponse{
+		Bucket:       bucket.Name,
+		Name:         fileMetadata.Filename,
+		Size:         fileMetadata.TotalSize,
+		Chunks:       fileMetadata.TotalChunks,
+		ETag:         fileMetadata.ETag,
+		ContentType:  fileMetadata.ContentType,
+		CreatedAt:    fileMetadata.CreatedAt,
+		LastModified: fileMetadata.UpdatedAt,
+	})
+	if err != nil {
+		http.Error(w, "Error forming response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
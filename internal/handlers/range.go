@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"s3-example/internal/storage"
+)
+
+var errNoOverlap = errors.New("invalid range: failed to overlap")
+
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+type chunkSlice struct {
+	metadata storage.ChunkMetadata
+	offset   int64
+	length   int64
+}
+
+// maxRanges bounds the number of ranges served in one multipart response.
+const maxRanges = 100
+
+// parseRange parses a Range header as described by RFC 7233. It returns nil
+// when the header is empty or the whole object should be served instead:
+// when the header asks for more than maxRanges ranges or for more bytes than
+// the object has, as net/http does. Overlapping and adjacent ranges are
+// merged.
+func parseRange(s string, size int64) ([]byteRange, error) {
+	if s == "" {
+		return nil, nil
+	}
+	const b = "bytes="
+	if !strings.HasPrefix(s, b) {
+		return nil, errors.New("invalid range")
+	}
+
+	var ranges []byteRange
+	noOverlap := false
+	for _, ra := range strings.Split(s[len(b):], ",") {
+		ra = textproto.TrimString(ra)
+		if ra == "" {
+			continue
+		}
+		start, end, ok := strings.Cut(ra, "-")
+		if !ok {
+			return nil, errors.New("invalid range")
+		}
+		start, end = textproto.TrimString(start), textproto.TrimString(end)
+
+		var r byteRange
+		if start == "" {
+			if end == "" || end[0] == '-' {
+				return nil, errors.New("invalid range")
+			}
+			i, err := strconv.ParseInt(end, 10, 64)
+			if i < 0 || err != nil {
+				return nil, errors.New("invalid range")
+			}
+			if i > size {
+				i = size
+			}
+			r.start = size - i
+			r.length = size - r.start
+		} else {
+			i, err := strconv.ParseInt(start, 10, 64)
+			if err != nil || i < 0 {
+				return nil, errors.New("invalid range")
+			}
+			if i >= size {
+				noOverlap = true
+				continue
+			}
+			r.start = i
+			if end == "" {
+				r.length = size - r.start
+			} else {
+				i, err := strconv.ParseInt(end, 10, 64)
+				if err != nil || r.start > i {
+					return nil, errors.New("invalid range")
+				}
+				if i >= size {
+					i = size - 1
+				}
+				r.length = i - r.start + 1
+			}
+		}
+		if r.length == 0 {
+			noOverlap = true
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+
+	if noOverlap && len(ranges) == 0 {
+		return nil, errNoOverlap
+	}
+	if len(ranges) > maxRanges {
+		return nil, nil
+	}
+	total := int64(0)
+	for _, r := range ranges {
+		total += r.length
+	}
+	if total > size {
+		return nil, nil
+	}
+	return mergeRanges(ranges), nil
+}
+
+// mergeRanges sorts the ranges and coalesces those that overlap or touch.
+func mergeRanges(ranges []byteRange) []byteRange {
+	slices.SortFunc(ranges, func(a, b byteRange) int {
+		return cmp.Compare(a.start, b.start)
+	})
+
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.start <= merged[n-1].start+merged[n-1].length {
+			last := &merged[n-1]
+			last.length = max(last.start+last.length, r.start+r.length) - last.start
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// ifRangeMatches reports whether a Range request should be honoured given
+// its If-Range precondition, which is either a strong entity tag or an
+// HTTP date that must equal the object's modification time.
+func ifRangeMatches(ifRange, etag string, lastModified time.Time) bool {
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+	if strings.HasPrefix(ifRange, "\"") {
+		return ifRange == etag
+	}
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	return lastModified.Truncate(time.Second).Equal(t)
+}
+
+// rangeSlices maps a byte range of the object onto the chunks that hold it,
+// with offsets relative to each chunk.
+func rangeSlices(chunks []storage.ChunkMetadata, r byteRange) []chunkSlice {
+	var slices []chunkSlice
+
+	end := r.start + r.length
+	chunkStart := int64(0)
+	for _, chunk := range chunks {
+		chunkEnd := chunkStart + chunk.ChunkSize
+		if chunkEnd > r.start && chunkStart < end {
+			offset := max(r.start, chunkStart) - chunkStart
+			length := min(end, chunkEnd) - chunkStart - offset
+			slices = append(slices, chunkSlice{metadata: chunk, offset: offset, length: length})
+		}
+		if chunkEnd >= end {
+			break
+		}
+		chunkStart = chunkEnd
+	}
+
+	return slices
+}
+
+func fullSlices(chunks []storage.ChunkMetadata) []chunkSlice {
+	slices := make([]chunkSlice, len(chunks))
+	for i, chunk := range chunks {
+		slices[i] = chunkSlice{metadata: chunk, length: chunk.ChunkSize}
+	}
+	return slices
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"s3-example/internal/storage"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		size    int64
+		want    []byteRange
+		wantErr error
+	}{
+		{name: "empty", header: "", size: 100, want: nil},
+		{name: "closed", header: "bytes=0-9", size: 100, want: []byteRange{{0, 10}}},
+		{name: "end past size", header: "bytes=90-200", size: 100, want: []byteRange{{90, 10}}},
+		{name: "open-ended", header: "bytes=95-", size: 100, want: []byteRange{{95, 5}}},
+		{name: "suffix", header: "bytes=-10", size: 100, want: []byteRange{{90, 10}}},
+		{name: "suffix longer than object", header: "bytes=-500", size: 100, want: []byteRange{{0, 100}}},
+		{name: "whitespace", header: "bytes= 0-4 , 10-14", size: 100, want: []byteRange{{0, 5}, {10, 5}}},
+		{name: "multi-range", header: "bytes=0-4,10-14,-5", size: 100, want: []byteRange{{0, 5}, {10, 5}, {95, 5}}},
+		{name: "sorted", header: "bytes=50-59,0-9", size: 100, want: []byteRange{{0, 10}, {50, 10}}},
+		{name: "overlapping merged", header: "bytes=0-9,5-19", size: 100, want: []byteRange{{0, 20}}},
+		{name: "adjacent merged", header: "bytes=0-9,10-19", size: 100, want: []byteRange{{0, 20}}},
+		{name: "contained merged", header: "bytes=0-49,10-19", size: 100, want: []byteRange{{0, 50}}},
+		{name: "unsatisfiable dropped", header: "bytes=0-9,200-300", size: 100, want: []byteRange{{0, 10}}},
+		{name: "more than the object", header: "bytes=0-79,20-99", size: 100, want: nil},
+		{name: "unsatisfiable", header: "bytes=100-200", size: 100, wantErr: errNoOverlap},
+		{name: "empty suffix of empty object", header: "bytes=-0", size: 100, wantErr: errNoOverlap},
+		{name: "any range of empty object", header: "bytes=0-", size: 0, wantErr: errNoOverlap},
+		{name: "wrong unit", header: "items=0-9", size: 100, wantErr: errors.New("invalid range")},
+		{name: "missing dash", header: "bytes=10", size: 100, wantErr: errors.New("invalid range")},
+		{name: "reversed", header: "bytes=9-0", size: 100, wantErr: errors.New("invalid range")},
+		{name: "negative suffix", header: "bytes=--5", size: 100, wantErr: errors.New("invalid range")},
+		{name: "not a number", header: "bytes=a-b", size: 100, wantErr: errors.New("invalid range")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRange(tt.header, tt.size)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("parseRange(%q) error = %v, want %v", tt.header, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRange(%q) error = %v", tt.header, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRange(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRangeLimitsRangeCount(t *testing.T) {
+	var specs []string
+	for i := 0; i <= maxRanges; i++ {
+		specs = append(specs, fmt.Sprintf("%d-%d", i*10, i*10))
+	}
+
+	got, err := parseRange("bytes="+strings.Join(specs, ","), 10000)
+	if err != nil || got != nil {
+		t.Errorf("parseRange with %d ranges = %v, %v; want the whole object", len(specs), got, err)
+	}
+}
+
+func TestRangeSlices(t *testing.T) {
+	chunks := []storage.ChunkMetadata{
+		{ChunkNumber: 0, ChunkSize: 10},
+		{ChunkNumber: 1, ChunkSize: 10},
+		{ChunkNumber: 2, ChunkSize: 5},
+	}
+
+	type slice struct {
+		chunk  int32
+		offset int64
+		length int64
+	}
+	tests := []struct {
+		name string
+		rng  byteRange
+		want []slice
+	}{
+		{name: "within one chunk", rng: byteRange{2, 5}, want: []slice{{0, 2, 5}}},
+		{name: "whole chunk", rng: byteRange{10, 10}, want: []slice{{1, 0, 10}}},
+		{name: "across chunks", rng: byteRange{8, 5}, want: []slice{{0, 8, 2}, {1, 0, 3}}},
+		{name: "suffix", rng: byteRange{20, 5}, want: []slice{{2, 0, 5}}},
+		{name: "everything", rng: byteRange{0, 25}, want: []slice{{0, 0, 10}, {1, 0, 10}, {2, 0, 5}}},
+		{name: "last byte", rng: byteRange{24, 1}, want: []slice{{2, 4, 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []slice
+			for _, s := range rangeSlices(chunks, tt.rng) {
+				got = append(got, slice{s.metadata.ChunkNumber, s.offset, s.length})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rangeSlices(%v) = %v, want %v", tt.rng, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"s3-example/internal/clients"
@@ -19,15 +20,16 @@ func NewRegistrationHandler(grpcClientManager *clients.GrpcClientManager) *Regis
 
 func (h *RegistrationHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ServiceName string `json:"service_name"`
-		GRPCAddress string `json:"grpc_address"`
+		ServiceName string  `json:"service_name"`
+		GRPCAddress string  `json:"grpc_address"`
+		Weight      float64 `json:"weight"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.grpcClientManager.RegisterClient(req.ServiceName, req.GRPCAddress); err != nil {
+	if err := h.grpcClientManager.RegisterClient(req.ServiceName, req.GRPCAddress, req.Weight); err != nil {
 		http.Error(w, "Failed to register client: "+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -39,9 +41,50 @@ func (h *RegistrationHandler) RegisterHandler(w http.ResponseWriter, r *http.Req
 	w.Write([]byte("Client registered successfully"))
 }
 
+func (h *RegistrationHandler) DeregisterHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ServiceName string `json:"service_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	err := h.grpcClientManager.DeregisterClient(req.ServiceName)
+	if errors.Is(err, clients.ErrClientNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error closing connection to %s: %v", req.ServiceName, err)
+	}
+
+	log.Printf("Deregistered client: %s. Total clients: %v", req.ServiceName, h.grpcClientManager.GetClientNames())
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Client deregistered successfully"))
+}
+
+// GetClientsHandler lists the registered nodes, or describes a single one
+// when service_name is given. Storage nodes use the latter to find out
+// whether they have to register again.
 func (h *RegistrationHandler) GetClientsHandler(w http.ResponseWriter, r *http.Request) {
-	clients := h.grpcClientManager.GetClientNames()
-	response, err := json.Marshal(clients)
+	var body interface{} = h.grpcClientManager.ListNodes()
+	if serviceName := r.URL.Query().Get("service_name"); serviceName != "" {
+		node, ok := h.grpcClientManager.GetNode(serviceName)
+		if !ok {
+			http.Error(w, clients.ErrClientNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		body = node
+	}
+
+	response, err := json.Marshal(body)
 	if err != nil {
 		http.Error(w, "Error forming response", http.StatusInternalServerError)
 		return
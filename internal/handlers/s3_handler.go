@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"s3-example/internal/chunker"
+	"s3-example/internal/storage"
+)
+
+const defaultMaxKeys = 1000
+
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// Bucket names that would be shadowed by the service's own endpoints.
+var reservedBucketNames = map[string]bool{
+	"upload":         true,
+	"download":       true,
+	"delete":         true,
+	"list":           true,
+	"stat":           true,
+	"register":       true,
+	"deregister":     true,
+	"clients":        true,
+	"corrupt-chunks": true,
+	"admin":          true,
+	"files":          true,
+}
+
+// S3Handler exposes objects through a subset of the S3 REST API using
+// path-style addressing (/bucket/key). Requests are not authenticated.
+type S3Handler struct {
+	fileHandler *FileHandler
+	dbManager   *storage.Manager
+}
+
+func NewS3Handler(fileHandler *FileHandler, dbManager *storage.Manager) *S3Handler {
+	return &S3Handler{
+		fileHandler: fileHandler,
+		dbManager:   dbManager,
+	}
+}
+
+func (h *S3Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Amz-Request-Id", newRequestID())
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	switch {
+	case bucket == "":
+		if r.Method != http.MethodGet {
+			writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+			return
+		}
+		h.listBuckets(w, r)
+	case key == "":
+		h.serveBucket(w, r, bucket)
+	default:
+		h.serveObject(w, r, bucket, key)
+	}
+}
+
+func (h *S3Handler) serveBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	switch r.Method {
+	case http.MethodPut:
+		h.createBucket(w, r, bucket)
+	case http.MethodDelete:
+		h.deleteBucket(w, r, bucket)
+	case http.MethodHead:
+		if _, ok := h.lookupBucket(w, r, bucket); ok {
+			w.WriteHeader(http.StatusOK)
+		}
+	case http.MethodGet:
+		if _, ok := r.URL.Query()["location"]; ok {
+			if _, ok := h.lookupBucket(w, r, bucket); ok {
+				writeXML(w, http.StatusOK, locationConstraint{Xmlns: s3Namespace})
+			}
+			return
+		}
+		h.listObjects(w, r, bucket)
+	default:
+		writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+}
+
+func (h *S3Handler) serveObject(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	bucket, ok := h.lookupBucket(w, r, bucketName)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if _, ok := query["uploads"]; ok && r.Method == http.MethodPost {
+		h.createMultipartUpload(w, r, bucket, key)
+		return
+	}
+	if query.Has("uploadId") {
+		switch r.Method {
+		case http.MethodPut:
+			h.uploadPart(w, r, bucket, key)
+		case http.MethodPost:
+			h.completeMultipartUpload(w, r, bucket, key)
+		case http.MethodGet:
+			h.listParts(w, r, bucket, key)
+		case http.MethodDelete:
+			h.abortMultipartUpload(w, r, bucket, key)
+		default:
+			writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		h.putObject(w, r, bucket, key)
+	case http.MethodGet:
+		h.getObject(w, r, bucket, key, true)
+	case http.MethodHead:
+		h.getObject(w, r, bucket, key, false)
+	case http.MethodDelete:
+		h.deleteObject(w, r, bucket, key)
+	default:
+		writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+}
+
+func (h *S3Handler) listBuckets(w http.ResponseWriter, r *http.Request) {
+	buckets, err := h.dbManager.ListBuckets()
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	owner := requestOwner(r)
+	result := listAllMyBucketsResult{
+		Xmlns: s3Namespace,
+		Owner: s3Owner{ID: owner, DisplayName: owner},
+	}
+	for _, bucket := range buckets {
+		result.Buckets = append(result.Buckets, s3Bucket{
+			Name:         bucket.Name,
+			CreationDate: s3Time(bucket.CreatedAt),
+		})
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *S3Handler) createBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	if !bucketNamePattern.MatchString(bucket) || reservedBucketNames[bucket] {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid.")
+		return
+	}
+
+	var settings map[string]string
+	if mode := r.Header.Get(chunkingHeader); mode != "" {
+		if !chunker.ValidMode(mode) {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "Unknown chunking mode.")
+			return
+		}
+		settings = map[string]string{chunkingSetting: mode}
+	}
+
+	_, err := h.dbManager.CreateBucket(bucket, requestOwner(r), settings)
+	if storage.IsUniqueViolation(err) {
+		writeS3Error(w, r, http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
+		return
+	}
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *S3Handler) deleteBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	b, ok := h.lookupBucket(w, r, bucket)
+	if !ok {
+		return
+	}
+	if b.Name == storage.DefaultBucketName {
+		writeS3Error(w, r, http.StatusConflict, "InvalidBucketState", "The default bucket cannot be deleted.")
+		return
+	}
+
+	files, err := h.dbManager.ListFiles(b.ID, "", "", 1)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	pending := false
+	if len(files) == 0 {
+		pending, err = h.dbManager.HasMultipartUploads(b.ID)
+		if err != nil {
+			writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+			return
+		}
+	}
+	if len(files) > 0 || pending {
+		writeS3Error(w, r, http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty.")
+		return
+	}
+
+	if err := h.dbManager.DeleteBucket(b.ID); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *S3Handler) listObjects(w http.ResponseWriter, r *http.Request, bucketName string) {
+	bucket, ok := h.lookupBucket(w, r, bucketName)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+
+	maxKeys := defaultMaxKeys
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "Provided max-keys not an integer or within integer range")
+			return
+		}
+		maxKeys = min(n, defaultMaxKeys)
+	}
+
+	startAfter := query.Get("start-after")
+	token := query.Get("continuation-token")
+	if token != "" {
+		decoded, err := base64.URLEncoding.DecodeString(token)
+		if err != nil {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect")
+			return
+		}
+		startAfter = string(decoded)
+	}
+
+	delimiter := query.Get("delimiter")
+
+	result := listBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              bucket.Name,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		StartAfter:        query.Get("start-after"),
+		ContinuationToken: token,
+		MaxKeys:           maxKeys,
+	}
+
+	listing, err := h.dbManager.ListObjects(bucket.ID, prefix, delimiter, startAfter, maxKeys)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	for _, file := range listing.Files {
+		result.Contents = append(result.Contents, s3Object{
+			Key:          file.Filename,
+			LastModified: s3Time(file.UpdatedAt),
+			ETag:         file.ETag,
+			Size:         file.TotalSize,
+			StorageClass: "STANDARD",
+		})
+	}
+	for _, commonPrefix := range listing.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: commonPrefix})
+	}
+	result.KeyCount = listing.Count()
+	result.IsTruncated = listing.IsTruncated
+	if listing.IsTruncated {
+		result.NextContinuationToken = base64.URLEncoding.EncodeToString([]byte(listing.NextMarker))
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *S3Handler) putObject(w http.ResponseWriter, r *http.Request, bucket *storage.Bucket, key string) {
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented", "CopyObject is not supported.")
+		return
+	}
+
+	opts := uploadOptions{}
+	var err error
+	opts.chunking, err = h.fileHandler.chunkingMode(r.Header.Get(chunkingHeader), bucket)
+	if err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", err.Error())
+		return
+	}
+	opts.dataKey, opts.key, err = h.fileHandler.newDataKey(r)
+	if err != nil {
+		writeEncryptionError(w, r, err)
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, h.fileHandler.cfg.MaxUploadSize)
+	if isAWSChunked(r) {
+		body = newAWSChunkedReader(body)
+	}
+
+	fileMetadata, err := h.fileHandler.storeObject(r.Context(), bucket.ID, key, objectContentType(r.Header.Get("Content-Type"), key), opts, body)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	setEncryptionHeaders(w, r, opts.dataKey)
+	w.Header().Set("ETag", fileMetadata.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *S3Handler) getObject(w http.ResponseWriter, r *http.Request, bucket *storage.Bucket, key string, withBody bool) {
+	fileMetadata, chunkMetadataList, err := h.fileHandler.loadObject(bucket.ID, key)
+	if errors.Is(err, errObjectNotFound) {
+		if withBody {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		return
+	}
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	dataKey, err := h.fileHandler.openDataKey(r, fileMetadata.DataKey)
+	if err != nil {
+		writeEncryptionError(w, r, err)
+		return
+	}
+	setEncryptionHeaders(w, r, fileMetadata.DataKey)
+
+	if err := h.fileHandler.serveObject(w, r, fileMetadata, chunkMetadataList, dataKey); err != nil {
+		fmt.Printf("GetObject '%s/%s' aborted: %v\n", bucket.Name, key, err)
+	}
+}
+
+func (h *S3Handler) deleteObject(w http.ResponseWriter, r *http.Request, bucket *storage.Bucket, key string) {
+	fileMetadata, err := h.dbManager.GetFileMetadata(bucket.ID, key)
+	if err == nil {
+		err = h.dbManager.DeleteFileMetadata(fileMetadata.ID)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeEncryptionError reports an error of newDataKey or openDataKey.
+func writeEncryptionError(w http.ResponseWriter, r *http.Request, err error) {
+	switch status := encryptionErrorStatus(err); status {
+	case http.StatusForbidden:
+		writeS3Error(w, r, status, "AccessDenied", err.Error())
+	case http.StatusBadRequest:
+		writeS3Error(w, r, status, "InvalidRequest", err.Error())
+	default:
+		writeS3Error(w, r, status, "InternalError", err.Error())
+	}
+}
+
+func (h *S3Handler) lookupBucket(w http.ResponseWriter, r *http.Request, bucket string) (*storage.Bucket, bool) {
+	b, err := h.dbManager.GetBucket(bucket)
+	if errors.Is(err, sql.ErrNoRows) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist.")
+		}
+		return nil, false
+	}
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return nil, false
+	}
+	return b, true
+}
+
+// requestOwner takes the access key ID from a SigV4 Authorization header.
+// Signatures are not verified, so this only labels buckets.
+func requestOwner(r *http.Request) string {
+	_, credential, ok := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+	if !ok {
+		return "anonymous"
+	}
+	accessKey, _, _ := strings.Cut(credential, "/")
+	return accessKey
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"s3-example/internal/storage"
+)
+
+const maxPartNumber = 10000
+
+func (h *S3Handler) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket *storage.Bucket, key string) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	uploadID := hex.EncodeToString(b)
+
+	// Parts are encrypted with the upload's data key, which the object
+	// takes over on completion.
+	dataKey, _, err := h.fileHandler.newDataKey(r)
+	if err != nil {
+		writeEncryptionError(w, r, err)
+		return
+	}
+
+	if _, err := h.dbManager.CreateMultipartUpload(bucket.ID, key, objectContentType(r.Header.Get("Content-Type"), key), uploadID, dataKey); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	setEncryptionHeaders(w, r, dataKey)
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Bucket:   bucket.Name,
+		Key:      key,
+		UploadID: uploadID,
+	})
+}
+
+func (h *S3Handler) uploadPart(w http.ResponseWriter, r *http.Request, bucket *storage.Bucket, key string) {
+	upload, ok := h.lookupUpload(w, r, bucket, key)
+	if !ok {
+		return
+	}
+
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive")
+		return
+	}
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented", "UploadPartCopy is not supported.")
+		return
+	}
+
+	opts := uploadOptions{dataKey: upload.DataKey}
+	opts.chunking, err = h.fileHandler.chunkingMode(r.Header.Get(chunkingHeader), bucket)
+	if err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", err.Error())
+		return
+	}
+	opts.key, err = h.fileHandler.openDataKey(r, upload.DataKey)
+	if err != nil {
+		writeEncryptionError(w, r, err)
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, h.fileHandler.cfg.MaxUploadSize)
+	if isAWSChunked(r) {
+		body = newAWSChunkedReader(body)
+	}
+
+	partID, err := h.dbManager.CreatePart(upload.ID, int32(partNumber))
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	etag, err := h.fileHandler.storePart(r.Context(), partID, key, opts, body)
+	if err != nil {
+		h.dbManager.DeletePart(partID)
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	setEncryptionHeaders(w, r, upload.DataKey)
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *S3Handler) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket *storage.Bucket, key string) {
+	upload, ok := h.lookupUpload(w, r, bucket, key)
+	if !ok {
+		return
+	}
+
+	var req completeMultipartUpload
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+		writeS3Error(w, r, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.")
+		return
+	}
+
+	uploaded, err := h.dbManager.ListParts(upload.ID)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	partsByNumber := make(map[int32]storage.MultipartPart, len(uploaded))
+	for _, part := range uploaded {
+		partsByNumber[part.PartNumber] = part
+	}
+
+	parts := make([]storage.MultipartPart, 0, len(req.Parts))
+	previous := int32(0)
+	for _, requested := range req.Parts {
+		if requested.PartNumber <= previous {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order.")
+			return
+		}
+		previous = requested.PartNumber
+
+		part, ok := partsByNumber[requested.PartNumber]
+		if !ok || strings.Trim(requested.ETag, `"`) != strings.Trim(part.ETag, `"`) {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+			return
+		}
+		parts = append(parts, part)
+	}
+
+	_, etag, err := h.dbManager.CompleteMultipartUpload(upload, parts)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: "/" + bucket.Name + "/" + key,
+		Bucket:   bucket.Name,
+		Key:      key,
+		ETag:     etag,
+	})
+}
+
+func (h *S3Handler) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket *storage.Bucket, key string) {
+	upload, ok := h.lookupUpload(w, r, bucket, key)
+	if !ok {
+		return
+	}
+
+	if err := h.dbManager.AbortMultipartUpload(upload.ID); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *S3Handler) listParts(w http.ResponseWriter, r *http.Request, bucket *storage.Bucket, key string) {
+	upload, ok := h.lookupUpload(w, r, bucket, key)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+
+	maxParts := defaultMaxKeys
+	if v := query.Get("max-parts"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "Provided max-parts not an integer or within integer range")
+			return
+		}
+		maxParts = min(n, defaultMaxKeys)
+	}
+
+	marker := 0
+	if v := query.Get("part-number-marker"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument", "Provided part-number-marker not an integer or within integer range")
+			return
+		}
+		marker = n
+	}
+
+	parts, err := h.dbManager.ListParts(upload.ID)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+
+	result := listPartsResult{
+		Xmlns:            s3Namespace,
+		Bucket:           bucket.Name,
+		Key:              key,
+		UploadID:         upload.UploadID,
+		PartNumberMarker: int32(marker),
+		MaxParts:         maxParts,
+	}
+	for _, part := range parts {
+		if part.PartNumber <= int32(marker) {
+			continue
+		}
+		if len(result.Parts) == maxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, s3Part{
+			PartNumber:   part.PartNumber,
+			LastModified: s3Time(part.CreatedAt),
+			ETag:         part.ETag,
+			Size:         part.TotalSize,
+		})
+		result.NextPartNumberMarker = part.PartNumber
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+func (h *S3Handler) lookupUpload(w http.ResponseWriter, r *http.Request, bucket *storage.Bucket, key string) (*storage.MultipartUpload, bool) {
+	upload, err := h.dbManager.GetMultipartUpload(r.URL.Query().Get("uploadId"))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (upload.BucketID != bucket.ID || upload.Filename != key)) {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist.")
+		return nil, false
+	}
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return nil, false
+	}
+	return upload, true
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"s3-example/internal/storage"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination"
+	tusBasePath   = "/files/"
+)
+
+// TusHandler implements the tus 1.0 resumable upload protocol (core,
+// creation and termination). Every PATCH request is stored as one part of a
+// multipart upload, so whatever was committed before a dropped connection
+// survives and the client resumes from the returned Upload-Offset.
+type TusHandler struct {
+	fileHandler *FileHandler
+	dbManager   *storage.Manager
+	sessions    *storage.SessionStore
+}
+
+func NewTusHandler(fileHandler *FileHandler, dbManager *storage.Manager, sessions *storage.SessionStore) *TusHandler {
+	return &TusHandler{
+		fileHandler: fileHandler,
+		dbManager:   dbManager,
+		sessions:    sessions,
+	}
+}
+
+func (h *TusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.fileHandler.cfg.MaxUploadSize, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "Unsupported tus version", http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, tusBasePath)
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		h.createUpload(w, r)
+	case id != "" && r.Method == http.MethodHead:
+		h.headUpload(w, r, id)
+	case id != "" && r.Method == http.MethodPatch:
+		h.patchUpload(w, r, id)
+	case id != "" && r.Method == http.MethodDelete:
+		h.terminateUpload(w, r, id)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *TusHandler) createUpload(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "Invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if length > h.fileHandler.cfg.MaxUploadSize {
+		http.Error(w, "Upload-Length exceeds Tus-Max-Size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, "Invalid Upload-Metadata: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		http.Error(w, "Filename not specified in Upload-Metadata", http.StatusBadRequest)
+		return
+	}
+	bucketName := metadata["bucket"]
+	if bucketName == "" {
+		bucketName = storage.DefaultBucketName
+	}
+
+	bucket, err := h.dbManager.GetBucket(bucketName)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errBucketNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error getting bucket: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	mode, err := h.fileHandler.chunkingMode(metadata["chunking"], bucket)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dataKey, _, err := h.fileHandler.newDataKey(r)
+	if err != nil {
+		http.Error(w, err.Error(), encryptionErrorStatus(err))
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id := hex.EncodeToString(b)
+
+	uploadID, err := h.dbManager.CreateMultipartUpload(bucket.ID, filename, objectContentType(metadata["filetype"], filename), id, dataKey)
+	if err == nil {
+		err = h.dbManager.ExtendMultipartUpload(uploadID, h.sessions.TTL())
+	}
+	if err != nil {
+		http.Error(w, "Error creating upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	session := &storage.UploadSession{
+		ID:       id,
+		UploadID: id,
+		Length:   length,
+		Chunking: mode,
+	}
+	if length == 0 {
+		if err := h.complete(session); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := h.sessions.Save(r.Context(), session); err != nil {
+		http.Error(w, "Error saving upload session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("Created resumable upload %s for '%s' (%d bytes)\n", id, filename, length)
+
+	w.Header().Set("Location", tusBasePath+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *TusHandler) headUpload(w http.ResponseWriter, r *http.Request, id string) {
+	session, ok := h.lookupSession(w, r, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TusHandler) patchUpload(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Invalid Content-Type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+
+	lock, err := h.sessions.Lock(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Error locking upload session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if lock == nil {
+		http.Error(w, "Upload is already in progress", http.StatusConflict)
+		return
+	}
+	defer lock.Unlock(context.WithoutCancel(r.Context()))
+
+	session, ok := h.lookupSession(w, r, id)
+	if !ok {
+		return
+	}
+	if session.Completed || offset != session.Offset {
+		http.Error(w, "Upload-Offset does not match the current offset", http.StatusConflict)
+		return
+	}
+
+	remaining := session.Length - session.Offset
+	if r.ContentLength > remaining {
+		http.Error(w, "Request body exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	upload, err := h.dbManager.GetMultipartUpload(session.UploadID)
+	if err != nil {
+		http.Error(w, "Error getting upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.dbManager.ExtendMultipartUpload(upload.ID, h.sessions.TTL()); err != nil {
+		http.Error(w, "Error extending upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// With SSE-C, every PATCH request has to carry the client's key.
+	opts := uploadOptions{chunking: session.Chunking, dataKey: upload.DataKey}
+	opts.key, err = h.fileHandler.openDataKey(r, upload.DataKey)
+	if err != nil {
+		http.Error(w, err.Error(), encryptionErrorStatus(err))
+		return
+	}
+
+	nodes := h.fileHandler.grpcClientManager.GetNodes()
+	if len(nodes) == 0 {
+		http.Error(w, "No available gRPC connections", http.StatusInternalServerError)
+		return
+	}
+
+	partID, err := h.dbManager.CreatePart(upload.ID, session.Parts+1)
+	if err != nil {
+		http.Error(w, "Error creating part: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The request context is cancelled when the client goes away, but the
+	// chunks it already sent must still reach the storage nodes.
+	ctx := context.WithoutCancel(r.Context())
+	body := io.LimitReader(r.Body, remaining)
+
+	// Sessions created before chunking was selectable have no mode.
+	if opts.chunking == "" {
+		opts.chunking = h.fileHandler.cfg.ChunkingMode
+	}
+
+	totalChunks, totalSize, err := h.fileHandler.uploadChunks(ctx, storage.ChunkMetadata{PartID: partID}, upload.Filename, opts, body, nodes)
+	var readErr *sourceReadError
+	if err != nil && !errors.As(err, &readErr) {
+		h.dbManager.DeletePart(partID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if readErr != nil {
+		fmt.Printf("Resumable upload %s interrupted after %d bytes: %v\n", id, totalSize, readErr)
+	}
+
+	if totalChunks == 0 {
+		h.dbManager.DeletePart(partID)
+	} else {
+		if _, err := h.fileHandler.finishPart(partID, totalChunks, totalSize); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		session.Parts++
+		session.Offset += totalSize
+	}
+
+	if session.Offset == session.Length {
+		if err := h.complete(session); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// The upload must outlive the session, which expires SessionTTL after
+	// this request rather than after it started.
+	if !session.Completed {
+		if err := h.dbManager.ExtendMultipartUpload(upload.ID, h.sessions.TTL()); err != nil {
+			http.Error(w, "Error extending upload: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := h.sessions.Save(ctx, session); err != nil {
+		http.Error(w, "Error saving upload session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TusHandler) terminateUpload(w http.ResponseWriter, r *http.Request, id string) {
+	session, ok := h.lookupSession(w, r, id)
+	if !ok {
+		return
+	}
+
+	if !session.Completed {
+		upload, err := h.dbManager.GetMultipartUpload(session.UploadID)
+		if err == nil {
+			err = h.dbManager.AbortMultipartUpload(upload.ID)
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Error aborting upload: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := h.sessions.Delete(r.Context(), id); err != nil {
+		http.Error(w, "Error deleting upload session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TusHandler) complete(session *storage.UploadSession) error {
+	upload, err := h.dbManager.GetMultipartUpload(session.UploadID)
+	if err != nil {
+		return fmt.Errorf("Error getting upload: %v", err)
+	}
+
+	parts, err := h.dbManager.ListParts(upload.ID)
+	if err != nil {
+		return fmt.Errorf("Error listing parts: %v", err)
+	}
+
+	if _, _, err := h.dbManager.CompleteMultipartUpload(upload, parts); err != nil {
+		return fmt.Errorf("Error completing upload: %v", err)
+	}
+
+	session.Completed = true
+	fmt.Printf("Resumable upload %s completed as '%s'\n", session.ID, upload.Filename)
+	return nil
+}
+
+func (h *TusHandler) lookupSession(w http.ResponseWriter, r *http.Request, id string) (*storage.UploadSession, bool) {
+	session, err := h.sessions.Get(r.Context(), id)
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		http.Error(w, "Upload not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Error getting upload session: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return session, true
+}
+
+// parseUploadMetadata decodes the Upload-Metadata header: comma-separated
+// "key base64(value)" pairs, where the value may be omitted.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s", key)
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUploadMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "empty",
+			header: "",
+			want:   map[string]string{},
+		},
+		{
+			name:   "single pair",
+			header: "filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==",
+			want:   map[string]string{"filename": "world_domination_plan.pdf"},
+		},
+		{
+			name:   "several pairs",
+			header: "filename YS50eHQ=,bucket cGhvdG9z,chunking ZmFzdGNkYw==",
+			want:   map[string]string{"filename": "a.txt", "bucket": "photos", "chunking": "fastcdc"},
+		},
+		{
+			name:   "spaces around pairs",
+			header: " filename YS50eHQ= , bucket cGhvdG9z ",
+			want:   map[string]string{"filename": "a.txt", "bucket": "photos"},
+		},
+		{
+			name:   "key without value",
+			header: "is_confidential,filename YS50eHQ=",
+			want:   map[string]string{"is_confidential": "", "filename": "a.txt"},
+		},
+		{
+			name:   "non-ASCII value",
+			header: "filename 0L7RgtGH0LXRgi5wZGY=",
+			want:   map[string]string{"filename": "отчет.pdf"},
+		},
+		{
+			name:    "empty pair",
+			header:  "filename YS50eHQ=,,bucket cGhvdG9z",
+			wantErr: true,
+		},
+		{
+			name:    "invalid base64",
+			header:  "filename not*base64",
+			wantErr: true,
+		},
+		{
+			name:    "unpadded base64",
+			header:  "filename YS50eHQ",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUploadMetadata(tt.header)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseUploadMetadata(%q) = %v, want an error", tt.header, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseUploadMetadata(%q) error = %v", tt.header, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseUploadMetadata(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
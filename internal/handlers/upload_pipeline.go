@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	filetransfer "s3-example/api/gen/go"
+	"s3-example/internal/placement"
+)
+
+// chunkSink keeps one TransferFile stream open per storage node and forwards
+// chunks to them as they are produced. At most window chunks are held in
+// memory at any time: Acquire blocks until a previously queued chunk has been
+// handed to its stream.
+type chunkSink struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	tokens  chan struct{}
+	streams map[string]*nodeStream
+	wg      sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+type nodeStream struct {
+	serviceName string
+	stream      filetransfer.FileTransferService_TransferFileClient
+	queue       chan queuedChunk
+}
+
+// queuedChunk is one replica of a chunk. The window slot is released when the
+// last replica has been handed to its stream.
+type queuedChunk struct {
+	chunk   *filetransfer.FileChunk
+	pending *atomic.Int32
+}
+
+// streamOpener opens the transfer streams of a chunkSink. It is implemented
+// by *clients.GrpcClientManager.
+type streamOpener interface {
+	GetClientByName(name string) filetransfer.FileTransferServiceClient
+	OpenTransferStream(ctx context.Context, client filetransfer.FileTransferServiceClient) (filetransfer.FileTransferService_TransferFileClient, error)
+}
+
+func newChunkSink(ctx context.Context, manager streamOpener, nodes []placement.Node, window int) (*chunkSink, error) {
+	if window < 1 {
+		window = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &chunkSink{
+		ctx:     ctx,
+		cancel:  cancel,
+		tokens:  make(chan struct{}, window),
+		streams: make(map[string]*nodeStream, len(nodes)),
+	}
+
+	for _, node := range nodes {
+		serviceName := node.Name
+		client := manager.GetClientByName(serviceName)
+		if client == nil {
+			cancel()
+			return nil, fmt.Errorf("gRPC client not found for service: %s", serviceName)
+		}
+
+		stream, err := manager.OpenTransferStream(ctx, client)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("Error opening stream to %s: %v", serviceName, err)
+		}
+
+		s.streams[serviceName] = &nodeStream{
+			serviceName: serviceName,
+			stream:      stream,
+			queue:       make(chan queuedChunk, window),
+		}
+	}
+
+	for _, ns := range s.streams {
+		s.wg.Add(1)
+		go s.run(ns)
+	}
+
+	return s, nil
+}
+
+func (s *chunkSink) run(ns *nodeStream) {
+	defer s.wg.Done()
+
+	for queued := range ns.queue {
+		if s.Err() == nil {
+			if err := ns.stream.Send(queued.chunk); err != nil {
+				s.fail(fmt.Errorf("Error sending chunk %d to %s: %v", queued.chunk.ChunkNumber, ns.serviceName, err))
+			}
+		}
+		if queued.pending.Add(-1) == 0 {
+			s.Release()
+		}
+	}
+
+	if s.Err() != nil {
+		return
+	}
+	if _, err := ns.stream.CloseAndRecv(); err != nil {
+		s.fail(fmt.Errorf("Error closing stream to %s: %v", ns.serviceName, err))
+	}
+}
+
+func (s *chunkSink) Acquire() error {
+	select {
+	case s.tokens <- struct{}{}:
+		return nil
+	case <-s.ctx.Done():
+		if err := s.Err(); err != nil {
+			return err
+		}
+		return s.ctx.Err()
+	}
+}
+
+func (s *chunkSink) Release() {
+	<-s.tokens
+}
+
+// Send queues the chunk for every node in serviceNames. It expects the
+// caller to hold a slot from Acquire; the slot is released once the chunk
+// has been written to all of those streams.
+func (s *chunkSink) Send(serviceNames []string, chunk *filetransfer.FileChunk) error {
+	streams := make([]*nodeStream, 0, len(serviceNames))
+	for _, serviceName := range serviceNames {
+		ns, ok := s.streams[serviceName]
+		if !ok {
+			s.Release()
+			return fmt.Errorf("No open stream for service: %s", serviceName)
+		}
+		streams = append(streams, ns)
+	}
+	if err := s.Err(); err != nil {
+		s.Release()
+		return err
+	}
+	if len(streams) == 0 {
+		s.Release()
+		return nil
+	}
+
+	pending := new(atomic.Int32)
+	pending.Store(int32(len(streams)))
+	for _, ns := range streams {
+		replica := &filetransfer.FileChunk{
+			Filename:    chunk.Filename,
+			Chunk:       chunk.Chunk,
+			ChunkNumber: chunk.ChunkNumber,
+			ChunkHash:   chunk.ChunkHash,
+			ServiceName: ns.serviceName,
+		}
+		ns.queue <- queuedChunk{chunk: replica, pending: pending}
+	}
+	return nil
+}
+
+func (s *chunkSink) Close() error {
+	for _, ns := range s.streams {
+		close(ns.queue)
+	}
+	s.wg.Wait()
+	s.cancel()
+
+	return s.Err()
+}
+
+func (s *chunkSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+func (s *chunkSink) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+
+	s.cancel()
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	filetransfer "s3-example/api/gen/go"
+	"s3-example/internal/placement"
+)
+
+// fakeStream records the chunk numbers sent to it. If release is set, every
+// Send waits for a value from it.
+type fakeStream struct {
+	filetransfer.FileTransferService_TransferFileClient
+
+	ctx      context.Context
+	release  chan struct{}
+	sendErr  error
+	closeErr error
+
+	mu     sync.Mutex
+	chunks []int32
+	closed bool
+}
+
+func (s *fakeStream) Send(chunk *filetransfer.FileChunk) error {
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+	if s.sendErr != nil {
+		return s.sendErr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks = append(s.chunks, chunk.ChunkNumber)
+	return nil
+}
+
+func (s *fakeStream) CloseAndRecv() (*filetransfer.TransferResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return &filetransfer.TransferResponse{}, s.closeErr
+}
+
+func (s *fakeStream) sent() []int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.chunks)
+}
+
+type fakeClient struct {
+	filetransfer.FileTransferServiceClient
+	name string
+}
+
+// fakeNodes opens the fake streams by node name.
+type fakeNodes map[string]*fakeStream
+
+func (n fakeNodes) GetClientByName(name string) filetransfer.FileTransferServiceClient {
+	if _, ok := n[name]; !ok {
+		return nil
+	}
+	return fakeClient{name: name}
+}
+
+func (n fakeNodes) OpenTransferStream(ctx context.Context, client filetransfer.FileTransferServiceClient) (filetransfer.FileTransferService_TransferFileClient, error) {
+	stream := n[client.(fakeClient).name]
+	stream.ctx = ctx
+	return stream, nil
+}
+
+func (n fakeNodes) nodes() []placement.Node {
+	var nodes []placement.Node
+	for name := range n {
+		nodes = append(nodes, placement.Node{Name: name})
+	}
+	return nodes
+}
+
+// sendChunk acquires a slot and queues the chunk for serviceNames.
+func sendChunk(sink *chunkSink, number int32, serviceNames ...string) error {
+	if err := sink.Acquire(); err != nil {
+		return err
+	}
+	return sink.Send(serviceNames, &filetransfer.FileChunk{ChunkNumber: number, ChunkHash: fmt.Sprint(number)})
+}
+
+// startAcquire calls Acquire in the background.
+func startAcquire(sink *chunkSink) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- sink.Acquire() }()
+	return done
+}
+
+// returned reports whether the call sends its result within the timeout,
+// and that result.
+func returned(done <-chan error, timeout time.Duration) (bool, error) {
+	select {
+	case err := <-done:
+		return true, err
+	case <-time.After(timeout):
+		return false, nil
+	}
+}
+
+func TestChunkSinkOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   int
+		replicas func(number int32) []string
+		want     map[string][]int32
+	}{
+		{
+			name:     "one node",
+			window:   2,
+			replicas: func(int32) []string { return []string{"a"} },
+			want:     map[string][]int32{"a": {0, 1, 2, 3, 4, 5}, "b": nil},
+		},
+		{
+			name:     "every chunk on both nodes",
+			window:   3,
+			replicas: func(int32) []string { return []string{"a", "b"} },
+			want:     map[string][]int32{"a": {0, 1, 2, 3, 4, 5}, "b": {0, 1, 2, 3, 4, 5}},
+		},
+		{
+			name:   "alternating nodes",
+			window: 1,
+			replicas: func(number int32) []string {
+				return []string{[]string{"a", "b"}[number%2]}
+			},
+			want: map[string][]int32{"a": {0, 2, 4}, "b": {1, 3, 5}},
+		},
+		{
+			name:     "already stored",
+			window:   2,
+			replicas: func(number int32) []string { return nil },
+			want:     map[string][]int32{"a": nil, "b": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := fakeNodes{"a": {}, "b": {}}
+			sink, err := newChunkSink(context.Background(), streams, streams.nodes(), tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for number := int32(0); number < 6; number++ {
+				if err := sendChunk(sink, number, tt.replicas(number)...); err != nil {
+					t.Fatalf("sending chunk %d: %v", number, err)
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			for name, want := range tt.want {
+				if got := streams[name].sent(); !slices.Equal(got, want) {
+					t.Errorf("%s received chunks %v, want %v", name, got, want)
+				}
+				if !streams[name].closed {
+					t.Errorf("stream to %s was not closed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkSinkWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   int
+		replicas []string
+	}{
+		{name: "window of one", window: 1, replicas: []string{"slow"}},
+		{name: "window of three", window: 3, replicas: []string{"slow"}},
+		{name: "held until the last replica", window: 2, replicas: []string{"fast", "slow"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slow := &fakeStream{release: make(chan struct{})}
+			streams := fakeNodes{"fast": {}, "slow": slow}
+			sink, err := newChunkSink(context.Background(), streams, streams.nodes(), tt.window)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for number := int32(0); number < int32(tt.window); number++ {
+				if err := sendChunk(sink, number, tt.replicas...); err != nil {
+					t.Fatal(err)
+				}
+			}
+			acquire := startAcquire(sink)
+			if acquired, _ := returned(acquire, 50*time.Millisecond); acquired {
+				t.Fatalf("Acquire() returned with %d chunks in flight", tt.window)
+			}
+
+			// The blocked Acquire gets the slot of the first chunk sent.
+			slow.release <- struct{}{}
+			if acquired, err := returned(acquire, time.Second); !acquired || err != nil {
+				t.Fatalf("Acquire() = %v, %v after a chunk was sent, want a slot", acquired, err)
+			}
+			sink.Release()
+
+			close(slow.release)
+			if err := sink.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if got := len(slow.sent()); got != tt.window {
+				t.Errorf("slow node received %d chunks, want %d", got, tt.window)
+			}
+		})
+	}
+}
+
+func TestChunkSinkErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream *fakeStream
+		// stops is set if sending fails before all chunks are queued.
+		stops   bool
+		wantErr string
+	}{
+		{name: "send fails", stream: &fakeStream{sendErr: errors.New("send failed")}, stops: true, wantErr: "send failed"},
+		{name: "close fails", stream: &fakeStream{closeErr: errors.New("close failed")}, wantErr: "close failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := fakeNodes{"good": {}, "bad": tt.stream}
+			sink, err := newChunkSink(context.Background(), streams, streams.nodes(), 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sendErr error
+			for number := int32(0); number < 20 && sendErr == nil; number++ {
+				sendErr = sendChunk(sink, number, "good", "bad")
+			}
+			if stopped := sendErr != nil; stopped != tt.stops {
+				t.Errorf("sending stopped: %v (%v), want %v", stopped, sendErr, tt.stops)
+			}
+
+			if err := sink.Close(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Close() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChunkSinkUnknownNode(t *testing.T) {
+	streams := fakeNodes{"a": {}}
+	if _, err := newChunkSink(context.Background(), streams, []placement.Node{{Name: "a"}, {Name: "b"}}, 1); err == nil {
+		t.Fatal("newChunkSink() opened a sink for an unknown node")
+	}
+
+	sink, err := newChunkSink(context.Background(), streams, streams.nodes(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sendChunk(sink, 0, "b"); err == nil {
+		t.Error("Send() queued a chunk for a node without a stream")
+	}
+	// The failed Send gave its slot back.
+	if acquired, _ := returned(startAcquire(sink), time.Second); !acquired {
+		t.Error("Acquire() blocked after a failed Send")
+	}
+	sink.Release()
+	if err := sink.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestChunkSinkCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	slow := &fakeStream{release: make(chan struct{})}
+	streams := fakeNodes{"slow": slow}
+	sink, err := newChunkSink(ctx, streams, streams.nodes(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for number := int32(0); number < 2; number++ {
+		if err := sendChunk(sink, number, "slow"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The next chunk waits for a slot until the upload is cancelled.
+	send := make(chan error, 1)
+	go func() { send <- sendChunk(sink, 2, "slow") }()
+	cancel()
+	if sent, err := returned(send, time.Second); !sent || err == nil {
+		t.Fatalf("sending a chunk after the upload was cancelled = %v, %v, want an error", sent, err)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- sink.Close() }()
+	if done, err := returned(closed, time.Second); !done || err == nil {
+		t.Errorf("Close() = %v, %v after the upload was cancelled, want an error", done, err)
+	}
+	if got := slow.sent(); len(got) != 0 {
+		t.Errorf("node received chunks %v after the upload was cancelled", got)
+	}
+}
@@ -0,0 +1,93 @@
+package placement
+
+import (
+	"math"
+	"sort"
+)
+
+const DefaultHighWaterMark = 0.9
+
+// Capacity places chunks by weighted rendezvous hashing, where a node's
+// weight is its free space multiplied by its configured weight, so nodes
+// with more room receive more chunks. Nodes whose disk usage has reached the
+// high-water mark receive no new chunks. Nodes that have not reported their
+// disk usage yet are assumed to have the average free space.
+type Capacity struct {
+	highWaterMark float64
+}
+
+func NewCapacity(highWaterMark float64) *Capacity {
+	if highWaterMark <= 0 || highWaterMark > 1 {
+		highWaterMark = DefaultHighWaterMark
+	}
+	return &Capacity{highWaterMark: highWaterMark}
+}
+
+func (c *Capacity) Place(key string, nodes []Node, count int) ([]string, error) {
+	var reported []Node
+	averageFree := 1.0
+	totalFree := 0.0
+	for _, node := range nodes {
+		if node.TotalBytes > 0 {
+			reported = append(reported, node)
+			totalFree += float64(node.FreeBytes)
+		}
+	}
+	if len(reported) > 0 && totalFree > 0 {
+		averageFree = totalFree / float64(len(reported))
+	}
+
+	type scoredNode struct {
+		name  string
+		score float64
+	}
+	var candidates []scoredNode
+	for _, node := range nodes {
+		free := averageFree
+		if node.TotalBytes > 0 {
+			if c.Overfull(node) {
+				continue
+			}
+			free = float64(node.FreeBytes)
+		}
+
+		weight := node.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+
+		// Uniform value in (0, 1) derived from the key and node.
+		u := (float64(hashKey(key+"\x00"+node.Name)>>11) + 0.5) / (1 << 53)
+		candidates = append(candidates, scoredNode{
+			name:  node.Name,
+			score: free * weight / -math.Log(u),
+		})
+	}
+
+	if count > len(candidates) {
+		return nil, ErrNotEnoughNodes
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	selected := make([]string, 0, count)
+	for _, candidate := range candidates[:count] {
+		selected = append(selected, candidate.name)
+	}
+	return selected, nil
+}
+
+// Overfull reports whether the node's disk usage has reached the high-water
+// mark. Nodes that have not reported their usage are never overfull.
+func (c *Capacity) Overfull(node Node) bool {
+	if node.TotalBytes == 0 {
+		return false
+	}
+	used := float64(node.TotalBytes-node.FreeBytes) / float64(node.TotalBytes)
+	return used >= c.highWaterMark || node.FreeBytes == 0
+}
@@ -0,0 +1,167 @@
+package placement
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func capacityNode(name string, weight float64, freeBytes, totalBytes uint64) Node {
+	return Node{Name: name, Weight: weight, FreeBytes: freeBytes, TotalBytes: totalBytes}
+}
+
+// placementShares places keys on one node each and returns the share of
+// keys every node received.
+func placementShares(t *testing.T, policy Placement, nodes []Node, keys int) map[string]float64 {
+	t.Helper()
+
+	shares := make(map[string]float64)
+	for i := 0; i < keys; i++ {
+		got, err := policy.Place(fmt.Sprintf("chunk-%d", i), nodes, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares[got[0]] += 1 / float64(keys)
+	}
+	return shares
+}
+
+func TestCapacityPlaceWeighting(t *testing.T) {
+	tests := []struct {
+		name   string
+		nodes  []Node
+		shares map[string]float64
+	}{
+		{
+			name:   "equal nodes",
+			nodes:  []Node{capacityNode("a", 1, 500, 1000), capacityNode("b", 1, 500, 1000)},
+			shares: map[string]float64{"a": 0.5, "b": 0.5},
+		},
+		{
+			name:   "more free space",
+			nodes:  []Node{capacityNode("a", 1, 300, 1000), capacityNode("b", 1, 600, 1000)},
+			shares: map[string]float64{"a": 1.0 / 3, "b": 2.0 / 3},
+		},
+		{
+			name:   "higher weight",
+			nodes:  []Node{capacityNode("a", 1, 500, 1000), capacityNode("b", 3, 500, 1000)},
+			shares: map[string]float64{"a": 0.25, "b": 0.75},
+		},
+		{
+			name:   "not reported counts as average",
+			nodes:  []Node{capacityNode("a", 1, 200, 1000), capacityNode("b", 1, 600, 1000), capacityNode("c", 1, 0, 0)},
+			shares: map[string]float64{"a": 1.0 / 6, "b": 0.5, "c": 1.0 / 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares := placementShares(t, NewCapacity(0.9), tt.nodes, 20000)
+			for name, want := range tt.shares {
+				if got := shares[name]; got < want-0.03 || got > want+0.03 {
+					t.Errorf("%s received %.3f of the keys, want %.3f", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCapacityPlaceExcludesOverfull(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodes    []Node
+		count    int
+		excluded []string
+		wantErr  error
+	}{
+		{
+			name:     "over the high-water mark",
+			nodes:    []Node{capacityNode("a", 1, 50, 1000), capacityNode("b", 1, 500, 1000), capacityNode("c", 1, 500, 1000)},
+			count:    2,
+			excluded: []string{"a"},
+		},
+		{
+			name:     "exactly at the high-water mark",
+			nodes:    []Node{capacityNode("a", 1, 100, 1000), capacityNode("b", 1, 500, 1000)},
+			count:    1,
+			excluded: []string{"a"},
+		},
+		{
+			name:     "no free space",
+			nodes:    []Node{capacityNode("a", 5, 0, 1000), capacityNode("b", 1, 500, 1000)},
+			count:    1,
+			excluded: []string{"a"},
+		},
+		{
+			name:  "not reported is never overfull",
+			nodes: []Node{capacityNode("a", 1, 0, 0), capacityNode("b", 1, 500, 1000)},
+			count: 2,
+		},
+		{
+			name:     "not enough room",
+			nodes:    []Node{capacityNode("a", 1, 50, 1000), capacityNode("b", 1, 500, 1000)},
+			count:    2,
+			excluded: []string{"a"},
+			wantErr:  ErrNotEnoughNodes,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewCapacity(0.9)
+			for _, node := range tt.nodes {
+				if got, want := policy.Overfull(node), slices.Contains(tt.excluded, node.Name); got != want {
+					t.Errorf("Overfull(%s) = %v, want %v", node.Name, got, want)
+				}
+			}
+
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("chunk-%d", i)
+				got, err := policy.Place(key, tt.nodes, tt.count)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Place(%q) error = %v, want %v", key, err, tt.wantErr)
+				}
+				for _, name := range got {
+					if slices.Contains(tt.excluded, name) {
+						t.Fatalf("Place(%q) = %v, includes the overfull node %s", key, got, name)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestCapacityPlaceNodeAdded checks that adding a node only moves keys to
+// it: the ranking of the other nodes stays the same.
+func TestCapacityPlaceNodeAdded(t *testing.T) {
+	before := []Node{capacityNode("a", 1, 500, 1000), capacityNode("b", 1, 500, 1000), capacityNode("c", 1, 500, 1000)}
+	after := append(slices.Clone(before), capacityNode("d", 1, 500, 1000))
+
+	policy := NewCapacity(0.9)
+	moved := 0
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("chunk-%d", i)
+		want, err := policy.Place(key, before, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := policy.Place(key, after, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		withoutNew := slices.DeleteFunc(slices.Clone(got), func(name string) bool { return name == "d" })
+		if !slices.Equal(withoutNew, want[:len(withoutNew)]) {
+			t.Fatalf("Place(%q) = %v with d added, was %v", key, got, want)
+		}
+		if !slices.Equal(got, want) {
+			moved++
+		}
+	}
+
+	// d is among the three nodes of about three quarters of the keys.
+	if moved < 1300 || moved > 1700 {
+		t.Errorf("%d of 2000 keys changed, want about 1500", moved)
+	}
+}
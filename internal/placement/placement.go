@@ -0,0 +1,29 @@
+package placement
+
+import "errors"
+
+var ErrNotEnoughNodes = errors.New("not enough storage nodes")
+
+// Node is a storage node that can receive chunks. Nodes with a higher weight
+// receive proportionally more chunks. TotalBytes is zero while the node has
+// not reported its disk usage yet.
+type Node struct {
+	Name       string
+	Weight     float64
+	FreeBytes  uint64
+	TotalBytes uint64
+}
+
+// Placement decides which storage nodes hold a chunk.
+type Placement interface {
+	// Place returns count distinct node names for the key, in preference
+	// order. The same key and node set always give the same answer.
+	Place(key string, nodes []Node, count int) ([]string, error)
+}
+
+// FillAware is implemented by policies that place chunks by free space.
+// Their choice changes as nodes fill up, so chunks are not rebalanced onto
+// it; they are only moved off nodes the policy reports as overfull.
+type FillAware interface {
+	Overfull(node Node) bool
+}
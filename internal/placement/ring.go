@@ -0,0 +1,118 @@
+package placement
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const DefaultVirtualNodes = 128
+
+type ringPoint struct {
+	hash uint64
+	node string
+}
+
+// Ring is a consistent-hash ring. Each node owns a number of virtual nodes
+// proportional to its weight, so adding or removing a node only moves the
+// keys that land on its points. The ring is rebuilt lazily whenever the node
+// set passed to Place changes.
+type Ring struct {
+	virtualNodes int
+
+	mu        sync.Mutex
+	signature string
+	points    []ringPoint
+}
+
+func NewRing(virtualNodes int) *Ring {
+	if virtualNodes < 1 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	return &Ring{virtualNodes: virtualNodes}
+}
+
+func (r *Ring) Place(key string, nodes []Node, count int) ([]string, error) {
+	if count > len(nodes) {
+		return nil, ErrNotEnoughNodes
+	}
+
+	points := r.ring(nodes)
+	target := hashKey(key)
+	start := sort.Search(len(points), func(i int) bool {
+		return points[i].hash >= target
+	})
+
+	selected := make([]string, 0, count)
+	seen := make(map[string]bool, count)
+	for i := 0; i < len(points) && len(selected) < count; i++ {
+		point := points[(start+i)%len(points)]
+		if !seen[point.node] {
+			seen[point.node] = true
+			selected = append(selected, point.node)
+		}
+	}
+
+	if len(selected) < count {
+		return nil, ErrNotEnoughNodes
+	}
+	return selected, nil
+}
+
+func (r *Ring) ring(nodes []Node) []ringPoint {
+	sorted := make([]Node, len(nodes))
+	copy(sorted, nodes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var sb strings.Builder
+	for _, node := range sorted {
+		sb.WriteString(node.Name)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.FormatFloat(node.Weight, 'g', -1, 64))
+		sb.WriteByte(';')
+	}
+	signature := sb.String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if signature == r.signature && r.points != nil {
+		return r.points
+	}
+
+	var points []ringPoint
+	for _, node := range sorted {
+		weight := node.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		replicas := max(int(math.Round(float64(r.virtualNodes)*weight)), 1)
+		for i := 0; i < replicas; i++ {
+			points = append(points, ringPoint{
+				hash: hashKey(node.Name + "#" + strconv.Itoa(i)),
+				node: node.Name,
+			})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].node < points[j].node
+	})
+
+	r.signature = signature
+	r.points = points
+	return points
+}
+
+func hashKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,130 @@
+package placement
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func testNodes(names ...string) []Node {
+	nodes := make([]Node, len(names))
+	for i, name := range names {
+		nodes[i] = Node{Name: name, Weight: 1}
+	}
+	return nodes
+}
+
+func TestRingPlaceDistinct(t *testing.T) {
+	tests := []struct {
+		name    string
+		nodes   []Node
+		count   int
+		wantErr error
+	}{
+		{name: "one of one", nodes: testNodes("a"), count: 1},
+		{name: "one of three", nodes: testNodes("a", "b", "c"), count: 1},
+		{name: "three of three", nodes: testNodes("a", "b", "c"), count: 3},
+		{name: "three of five", nodes: testNodes("a", "b", "c", "d", "e"), count: 3},
+		{name: "weighted", nodes: []Node{{Name: "a", Weight: 0.1}, {Name: "b", Weight: 4}, {Name: "c"}}, count: 3},
+		{name: "more than nodes", nodes: testNodes("a", "b"), count: 3, wantErr: ErrNotEnoughNodes},
+		{name: "no nodes", nodes: nil, count: 1, wantErr: ErrNotEnoughNodes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := NewRing(16)
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("chunk-%d", i)
+				got, err := ring.Place(key, tt.nodes, tt.count)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("Place(%q) error = %v, want %v", key, err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Place(%q) error = %v", key, err)
+				}
+				if len(got) != tt.count {
+					t.Fatalf("Place(%q) = %v, want %d nodes", key, got, tt.count)
+				}
+				seen := make(map[string]bool)
+				for _, name := range got {
+					if seen[name] {
+						t.Fatalf("Place(%q) = %v, has duplicates", key, got)
+					}
+					seen[name] = true
+				}
+			}
+		})
+	}
+}
+
+func TestRingPlaceDeterministic(t *testing.T) {
+	nodes := testNodes("a", "b", "c", "d")
+	reversed := slices.Clone(nodes)
+	slices.Reverse(reversed)
+
+	first, second := NewRing(32), NewRing(32)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("chunk-%d", i)
+		want, _ := first.Place(key, nodes, 2)
+		got, _ := second.Place(key, reversed, 2)
+		if !slices.Equal(got, want) {
+			t.Fatalf("Place(%q) = %v with the nodes reversed, want %v", key, got, want)
+		}
+	}
+}
+
+// TestRingPlaceStability checks that a change of the node set only moves the
+// keys that involve the added or removed node, and not too many of them.
+func TestRingPlaceStability(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  []Node
+		after   []Node
+		changed string
+		count   int
+	}{
+		{name: "add to three", before: testNodes("a", "b", "c"), after: testNodes("a", "b", "c", "d"), changed: "d", count: 1},
+		{name: "add to three, two copies", before: testNodes("a", "b", "c"), after: testNodes("a", "b", "c", "d"), changed: "d", count: 2},
+		{name: "remove from four", before: testNodes("a", "b", "c", "d"), after: testNodes("a", "b", "d"), changed: "c", count: 1},
+		{name: "remove from five, three copies", before: testNodes("a", "b", "c", "d", "e"), after: testNodes("a", "c", "d", "e"), changed: "b", count: 3},
+	}
+
+	const keys = 2000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One ring per node set, so neither is rebuilt for every key.
+			beforeRing, afterRing := NewRing(DefaultVirtualNodes), NewRing(DefaultVirtualNodes)
+			moved := 0
+			for i := 0; i < keys; i++ {
+				key := fmt.Sprintf("chunk-%d", i)
+				before, err := beforeRing.Place(key, tt.before, tt.count)
+				if err != nil {
+					t.Fatal(err)
+				}
+				after, err := afterRing.Place(key, tt.after, tt.count)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if slices.Equal(before, after) {
+					continue
+				}
+				moved++
+				if !slices.Contains(before, tt.changed) && !slices.Contains(after, tt.changed) {
+					t.Fatalf("Place(%q) moved from %v to %v without involving %s", key, before, after, tt.changed)
+				}
+			}
+
+			// Each node owns about count/len(nodes) of the keys; allow for
+			// the uneven spread of virtual nodes.
+			largest := max(len(tt.before), len(tt.after))
+			if limit := 2 * keys * tt.count / largest; moved > limit {
+				t.Errorf("%d of %d keys moved, want at most %d", moved, keys, limit)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package server
+
+import "errors"
+
+func diskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("disk statistics are not supported on this platform")
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
@@ -12,6 +13,8 @@ import (
 	filetransfer "s3-example/api/gen/go"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 const filesPath = "files"
@@ -59,12 +62,23 @@ func (s *FileTransferServer) saveChunk(chunk *filetransfer.FileChunk) error {
 
 	chunkPath := filepath.Join(fileDir, chunkFilename)
 
-	err = os.WriteFile(chunkPath, chunk.Chunk, os.ModePerm)
+	// Write to a temporary file first so that readers and the scrubber
+	// never see a partially written chunk.
+	tmpFile, err := os.CreateTemp(fileDir, chunkFilename+".*.tmp")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmpFile.Name())
 
-	return nil
+	_, err = tmpFile.Write(chunk.Chunk)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), chunkPath)
 }
 
 func (s *FileTransferServer) GetChunk(ctx context.Context, req *filetransfer.ChunkRequest) (*filetransfer.ChunkResponse, error) {
@@ -87,6 +101,72 @@ func (s *FileTransferServer) GetChunk(ctx context.Context, req *filetransfer.Chu
 	}, nil
 }
 
+// DeleteChunk removes a chunk file. Deleting a chunk that is already gone is
+// not an error, so purges can safely be retried.
+func (s *FileTransferServer) DeleteChunk(ctx context.Context, req *filetransfer.DeleteChunkRequest) (*filetransfer.DeleteChunkResponse, error) {
+	chunkHash := req.ChunkHash
+	if !isChunkHash(chunkHash) {
+		return nil, fmt.Errorf("Invalid chunk hash")
+	}
+
+	chunkPath := filepath.Join(s.StorageDir, filesPath, s.ServiceName, chunkHash)
+
+	err := os.Remove(chunkPath)
+	if os.IsNotExist(err) {
+		return &filetransfer.DeleteChunkResponse{Deleted: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Chunk %s deleted", chunkHash)
+	return &filetransfer.DeleteChunkResponse{Deleted: true}, nil
+}
+
+// NodeStats reports the disk space of the filesystem under StorageDir and
+// how much of it this node's chunks take up.
+func (s *FileTransferServer) NodeStats(ctx context.Context, req *filetransfer.NodeStatsRequest) (*filetransfer.NodeStatsResponse, error) {
+	free, total, err := diskSpace(s.StorageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var used uint64
+	var chunkCount int64
+	fileDir := filepath.Join(s.StorageDir, filesPath, s.ServiceName)
+	entries, err := os.ReadDir(fileDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		// Skip temporary files of writes in progress.
+		if !entry.Type().IsRegular() || !isChunkHash(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		used += uint64(info.Size())
+		chunkCount++
+	}
+
+	return &filetransfer.NodeStatsResponse{
+		FreeBytes:  free,
+		UsedBytes:  used,
+		TotalBytes: total,
+		ChunkCount: chunkCount,
+	}, nil
+}
+
+func isChunkHash(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
 func StartStorageGRPCServer(port string, storageDir string, serviceName string) error {
 	listener, err := net.Listen("tcp", ":"+port)
 	if err != nil {
@@ -96,6 +176,7 @@ func StartStorageGRPCServer(port string, storageDir string, serviceName string)
 	server := grpc.NewServer()
 	ftServer := NewFileTransferServer(storageDir, serviceName)
 	filetransfer.RegisterFileTransferServiceServer(server, ftServer)
+	healthpb.RegisterHealthServer(server, health.NewServer())
 
 	log.Printf("StorageService '%s' gRPC server started on port %s", serviceName, port)
 	return server.Serve(listener)
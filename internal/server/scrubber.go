@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	quarantinePath = "quarantine"
+	reportedSuffix = ".reported"
+)
+
+// Scrubber periodically re-hashes the chunk files of a storage node. Chunk
+// files are named by their SHA-256, so a file whose content no longer
+// matches its name is corrupt: it is moved to StorageDir/quarantine/<service>
+// and reported, so that the transfer service stops reading it and restores
+// the lost copy elsewhere. Reading is throttled to bytesPerSecond to keep
+// the scrubber from competing with client traffic.
+type Scrubber struct {
+	storageDir     string
+	serviceName    string
+	interval       time.Duration
+	bytesPerSecond int64
+	report         func(chunkHash string) error
+}
+
+func NewScrubber(storageDir, serviceName string, interval time.Duration, bytesPerSecond int64, report func(chunkHash string) error) *Scrubber {
+	return &Scrubber{
+		storageDir:     storageDir,
+		serviceName:    serviceName,
+		interval:       interval,
+		bytesPerSecond: bytesPerSecond,
+		report:         report,
+	}
+}
+
+// Run scrubs every interval until ctx is cancelled. A zero interval
+// disables scrubbing.
+func (s *Scrubber) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reportQuarantined()
+			if err := s.scrub(ctx); err != nil {
+				log.Printf("Error scrubbing chunks: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Scrubber) scrub(ctx context.Context) error {
+	fileDir := filepath.Join(s.storageDir, filesPath, s.serviceName)
+	entries, err := os.ReadDir(fileDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	limiter := newRateLimiter(s.bytesPerSecond)
+	checked, corrupt := 0, 0
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		chunkHash := entry.Name()
+		if !entry.Type().IsRegular() || !isChunkHash(chunkHash) {
+			continue
+		}
+
+		actualHash, err := hashFile(ctx, filepath.Join(fileDir, chunkHash), limiter)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error scrubbing chunk %s: %v", chunkHash, err)
+			continue
+		}
+		checked++
+
+		if actualHash == chunkHash {
+			continue
+		}
+		corrupt++
+
+		log.Printf("Chunk %s is corrupt (content hash %s), quarantining", chunkHash, actualHash)
+		if err := s.quarantine(chunkHash); err != nil {
+			log.Printf("Error quarantining chunk %s: %v", chunkHash, err)
+			continue
+		}
+		s.reportChunk(chunkHash)
+	}
+
+	log.Printf("Scrub finished: %d chunks checked, %d corrupt", checked, corrupt)
+	return nil
+}
+
+func (s *Scrubber) quarantine(chunkHash string) error {
+	quarantineDir := filepath.Join(s.storageDir, quarantinePath, s.serviceName)
+	if err := os.MkdirAll(quarantineDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	return os.Rename(
+		filepath.Join(s.storageDir, filesPath, s.serviceName, chunkHash),
+		filepath.Join(quarantineDir, chunkHash),
+	)
+}
+
+// reportQuarantined retries the reports that failed on earlier passes.
+// Quarantined files are renamed once their report has been accepted.
+func (s *Scrubber) reportQuarantined() {
+	quarantineDir := filepath.Join(s.storageDir, quarantinePath, s.serviceName)
+	entries, err := os.ReadDir(quarantineDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if isChunkHash(entry.Name()) {
+			s.reportChunk(entry.Name())
+		}
+	}
+}
+
+func (s *Scrubber) reportChunk(chunkHash string) {
+	if err := s.report(chunkHash); err != nil {
+		log.Printf("Error reporting corrupt chunk %s: %v", chunkHash, err)
+		return
+	}
+
+	quarantined := filepath.Join(s.storageDir, quarantinePath, s.serviceName, chunkHash)
+	if err := os.Rename(quarantined, quarantined+reportedSuffix); err != nil {
+		log.Printf("Error marking chunk %s as reported: %v", chunkHash, err)
+	}
+}
+
+func hashFile(ctx context.Context, path string, limiter *rateLimiter) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buf)
+		hash.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := limiter.wait(ctx, n); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// rateLimiter paces reads so that on average no more than bytesPerSecond
+// bytes are read since it was created. Zero disables the limit.
+type rateLimiter struct {
+	bytesPerSecond int64
+	start          time.Time
+	total          int64
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{
+		bytesPerSecond: bytesPerSecond,
+		start:          time.Now(),
+	}
+}
+
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l.bytesPerSecond <= 0 {
+		return nil
+	}
+
+	l.total += int64(n)
+	due := l.start.Add(time.Duration(float64(l.total) / float64(l.bytesPerSecond) * float64(time.Second)))
+	delay := time.Until(due)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package sse
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KMS wraps data keys with master keys that never leave it.
+type KMS interface {
+	// Wrap encrypts a data key with the current master key and returns the
+	// id of that master key along with the wrapped key.
+	Wrap(dataKey []byte) (keyID string, wrapped []byte, err error)
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKMS keeps its master keys in a local keyfile. Every non-empty line
+// that is not a comment holds a key id and a base64-encoded 32-byte key, as
+// printed by "openssl rand -base64 32":
+//
+//	# id     key
+//	2026-10  <base64 key>
+//
+// The last key wraps new data keys; the earlier ones are kept to unwrap the
+// keys of existing objects after a rotation.
+type LocalKMS struct {
+	keys    map[string][]byte
+	current string
+}
+
+func NewLocalKMS(path string) (*LocalKMS, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	kms := &LocalKMS{keys: make(map[string][]byte)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a key id and a key", path, line)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("%s:%d: key must be %d bytes, base64-encoded", path, line, KeySize)
+		}
+		if _, ok := kms.keys[fields[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate key id %q", path, line, fields[0])
+		}
+
+		kms.keys[fields[0]] = key
+		kms.current = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if kms.current == "" {
+		return nil, fmt.Errorf("%s: no master key found", path)
+	}
+
+	return kms, nil
+}
+
+func (k *LocalKMS) Wrap(dataKey []byte) (string, []byte, error) {
+	wrapped, err := Seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return "", nil, err
+	}
+	return k.current, wrapped, nil
+}
+
+func (k *LocalKMS) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %q", keyID)
+	}
+	return Open(key, wrapped, []byte(keyID))
+}
@@ -0,0 +1,78 @@
+package sse
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Encryption modes of an object.
+const (
+	// ModeNone stores the chunks of the object in plain.
+	ModeNone = "none"
+	// ModeKMS wraps the data key of the object with a KMS master key.
+	ModeKMS = "kms"
+	// ModeCustomer wraps the data key with a key the client supplies with
+	// every request (SSE-C). The server never stores that key.
+	ModeCustomer = "customer"
+)
+
+// KeySize is the size of data, master and customer keys: AES-256.
+const KeySize = 32
+
+var ErrDecrypt = errors.New("message authentication failed")
+
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext with AES-GCM under key and a random nonce, which
+// is prepended to the result. aad is authenticated but not encrypted.
+func Seal(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open reverses Seal. It returns ErrDecrypt if the key or aad is wrong or
+// the data has been tampered with.
+func Open(key, sealed, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key size %d, expected %d", len(key), KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
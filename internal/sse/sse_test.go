@@ -0,0 +1,92 @@
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+	otherKey := bytes.Repeat([]byte{2}, KeySize)
+	plaintext := []byte("chunk data")
+
+	tamper := func(sealed []byte) []byte {
+		tampered := bytes.Clone(sealed)
+		tampered[len(tampered)-1] ^= 1
+		return tampered
+	}
+
+	tests := []struct {
+		name     string
+		openKey  []byte
+		sealAAD  []byte
+		openAAD  []byte
+		modify   func([]byte) []byte
+		wantErr  error
+		wantText []byte
+	}{
+		{name: "round-trip", openKey: key, wantText: plaintext},
+		{name: "round-trip with aad", openKey: key, sealAAD: []byte("hash"), openAAD: []byte("hash"), wantText: plaintext},
+		{name: "aad mismatch", openKey: key, sealAAD: []byte("hash"), openAAD: []byte("other"), wantErr: ErrDecrypt},
+		{name: "aad missing", openKey: key, sealAAD: []byte("hash"), wantErr: ErrDecrypt},
+		{name: "wrong key", openKey: otherKey, wantErr: ErrDecrypt},
+		{name: "tampered", openKey: key, modify: tamper, wantErr: ErrDecrypt},
+		{name: "truncated", openKey: key, modify: func(b []byte) []byte { return b[:10] }, wantErr: ErrDecrypt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := Seal(key, plaintext, tt.sealAAD)
+			if err != nil {
+				t.Fatalf("Seal() error = %v", err)
+			}
+			if tt.modify != nil {
+				sealed = tt.modify(sealed)
+			}
+
+			got, err := Open(tt.openKey, sealed, tt.openAAD)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Open() error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.wantText) {
+				t.Errorf("Open() = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestSealUsesFreshNonces(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, KeySize)
+
+	first, err := Seal(key, []byte("data"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Seal(key, []byte("data"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("sealing the same data twice gave the same output")
+	}
+}
+
+func TestSealRejectsInvalidKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{name: "nil", key: nil},
+		{name: "AES-128", key: make([]byte, 16)},
+		{name: "too long", key: make([]byte, KeySize+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Seal(tt.key, []byte("data"), nil); err == nil {
+				t.Error("Seal() accepted the key")
+			}
+		})
+	}
+}
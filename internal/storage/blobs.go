@@ -0,0 +1,46 @@
+package storage
+
+import "database/sql"
+
+// Chunk files are content addressed, so every replica of the same hash on a
+// node shares one file. The chunk_blobs table counts those references and
+// queues the file for purging when the count drops to zero; it is kept up to
+// date by triggers on chunk_replicas.
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// MarkChunkBlobsStored records that the chunk files of the file or part set
+// in owner have been written to their nodes, so later uploads of the same
+// chunks can skip sending them.
+func (m *Manager) MarkChunkBlobsStored(owner ChunkMetadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `UPDATE chunk_blobs b SET stored = TRUE
+              FROM chunk_replicas r
+              JOIN chunks c ON c.id = r.chunk_id
+              WHERE b.chunk_hash = r.chunk_hash AND b.service_name = r.service_name
+                AND NOT b.stored
+                AND (c.file_id = $1 OR c.part_id = $2);`
+	_, err := m.DB.Exec(query, nullableID(owner.FileID), nullableID(owner.PartID))
+	return err
+}
+
+// MarkChunkBlobStored records that a chunk file has been written to the
+// node.
+func (m *Manager) MarkChunkBlobStored(serviceName, chunkHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return markBlobStored(m.DB, serviceName, chunkHash)
+}
+
+func markBlobStored(db execer, serviceName, chunkHash string) error {
+	query := `UPDATE chunk_blobs SET stored = TRUE
+              WHERE chunk_hash = $1 AND service_name = $2 AND NOT stored;`
+	_, err := db.Exec(query, chunkHash, serviceName)
+	return err
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const DefaultBucketName = "default"
+
+type Bucket struct {
+	ID        int64
+	Name      string
+	Owner     string
+	CreatedAt time.Time
+	Settings  map[string]string
+}
+
+func (m *Manager) CreateBucket(name, owner string, settings map[string]string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if settings == nil {
+		settings = map[string]string{}
+	}
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return 0, err
+	}
+
+	var bucketID int64
+	query := `INSERT INTO buckets (name, owner, settings) VALUES ($1, $2, $3) RETURNING id;`
+	err = m.DB.QueryRow(query, name, owner, settingsJSON).Scan(&bucketID)
+	if err != nil {
+		return 0, err
+	}
+	return bucketID, nil
+}
+
+func (m *Manager) GetBucket(name string) (*Bucket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `SELECT id, name, owner, created_at, settings FROM buckets WHERE name = $1;`
+	var bucket Bucket
+	var settingsJSON []byte
+	err := m.DB.QueryRow(query, name).Scan(&bucket.ID, &bucket.Name, &bucket.Owner, &bucket.CreatedAt, &settingsJSON)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(settingsJSON, &bucket.Settings); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+func (m *Manager) ListBuckets() ([]Bucket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `SELECT id, name, owner, created_at, settings FROM buckets ORDER BY name ASC;`
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []Bucket
+	for rows.Next() {
+		var bucket Bucket
+		var settingsJSON []byte
+		if err := rows.Scan(&bucket.ID, &bucket.Name, &bucket.Owner, &bucket.CreatedAt, &settingsJSON); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(settingsJSON, &bucket.Settings); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+func (m *Manager) UpdateBucketSettings(bucketID int64, settings map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE buckets SET settings = $1 WHERE id = $2;`
+	_, err = m.DB.Exec(query, settingsJSON, bucketID)
+	return err
+}
+
+func (m *Manager) DeleteBucket(bucketID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `DELETE FROM buckets WHERE id = $1;`
+	_, err := m.DB.Exec(query, bucketID)
+	return err
+}
@@ -2,28 +2,69 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type FileMetadata struct {
 	ID          int64
+	BucketID    int64
 	Filename    string
 	TotalChunks int32
 	TotalSize   int64
+	ContentType string
+	DataKey     DataKey
+	// ETag is computed from the chunk hashes when the object is stored.
+	ETag      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// DataKey is the wrapped data key of an object. Encryption is "none" for
+// objects stored in plain. MasterKeyID names the KMS master key the data
+// key is wrapped with; it is empty for keys wrapped with a key supplied by
+// the client.
+type DataKey struct {
+	Encryption  string
+	MasterKeyID string
+	Wrapped     []byte
 }
 
 type ChunkMetadata struct {
 	ID          int64
 	FileID      int64
+	PartID      int64
 	ChunkNumber int32
-	ServiceName string
 	ChunkSize   int64
+	// ChunkHash is the SHA-256 of the chunk's data. Compression names the
+	// codec the chunk is stored with on the nodes, and StoredHash is the
+	// SHA-256 of the stored bytes, which name the chunk file on the nodes
+	// and its replicas. Both hashes are equal for uncompressed chunks.
 	ChunkHash   string
+	Compression string
+	StoredHash  string
+	// StoredSize is the length of the stored bytes. Encrypted chunks are
+	// compressed first and then sealed with their object's data key.
+	StoredSize int64
+	Encrypted  bool
+	// Replicas lists the storage nodes holding a copy of the chunk, in
+	// preference order.
+	Replicas []string
+	// StripeNumber is NoStripe for replicated chunks. Erasure-coded chunks
+	// belong to the stripe numbered after its first data chunk, at position
+	// ShardIndex; parity chunks follow the data chunks of their stripe.
+	StripeNumber int32
+	ShardIndex   int32
+	IsParity     bool
 }
 
+const NoStripe = -1
+
 type Manager struct {
 	DB *sql.DB
 	mu sync.Mutex
@@ -44,109 +85,227 @@ func NewDBManager(pgHost, pgPort, pgUser, pgPassword, pgDBName string) (*Manager
 	return manager, nil
 }
 
-func (m *Manager) CreateFileMetadata(filename string, totalChunks int32, totalSize int64) (int64, error) {
+func (m *Manager) GetFileID(bucketID int64, filename string) (int64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	var fileID int64
-	query := `INSERT INTO files (filename, total_chunks, total_size)
-              VALUES ($1, $2, $3)
-              RETURNING id;`
-	err := m.DB.QueryRow(query, filename, totalChunks, totalSize).Scan(&fileID)
+	query := `SELECT id FROM files WHERE bucket_id = $1 AND filename = $2;`
+	err := m.DB.QueryRow(query, bucketID, filename).Scan(&fileID)
 	if err != nil {
 		return 0, err
 	}
 	return fileID, nil
 }
 
-func (m *Manager) UpdateFileMetadata(fileID int64, totalChunks int32, totalSize int64) error {
+// SaveChunkMetadata records a chunk together with all of its replicas. It
+// returns the replicas whose node already stores a chunk with the same
+// stored hash, so the data does not have to be sent there again.
+func (m *Manager) SaveChunkMetadata(metadata ChunkMetadata) ([]string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	query := `UPDATE files SET total_chunks = $1, total_size = $2 WHERE id = $3;`
-	_, err := m.DB.Exec(query, totalChunks, totalSize, fileID)
-	return err
-}
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-func (m *Manager) GetFileID(filename string) (int64, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	stripeNumber := sql.NullInt32{Int32: metadata.StripeNumber, Valid: metadata.StripeNumber != NoStripe}
+	shardIndex := sql.NullInt32{Int32: metadata.ShardIndex, Valid: stripeNumber.Valid}
 
-	var fileID int64
-	query := `SELECT id FROM files WHERE filename = $1;`
-	err := m.DB.QueryRow(query, filename).Scan(&fileID)
+	var chunkID int64
+	query := `INSERT INTO chunks (file_id, part_id, chunk_number, chunk_size, chunk_hash, compression, stored_hash, stored_size, encrypted,
+                                  is_parity, stripe_number, shard_index)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+              RETURNING id;`
+	err = tx.QueryRow(query, nullableID(metadata.FileID), nullableID(metadata.PartID), metadata.ChunkNumber, metadata.ChunkSize, metadata.ChunkHash,
+		metadata.Compression, metadata.StoredHash, metadata.StoredSize, metadata.Encrypted, metadata.IsParity, stripeNumber, shardIndex).Scan(&chunkID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return fileID, nil
+
+	for i, serviceName := range metadata.Replicas {
+		query := `INSERT INTO chunk_replicas (chunk_id, service_name, chunk_hash, replica_index)
+                  VALUES ($1, $2, $3, $4);`
+		if _, err := tx.Exec(query, chunkID, serviceName, metadata.StoredHash, i); err != nil {
+			return nil, err
+		}
+	}
+
+	// The new replicas already hold a reference, so a stored blob cannot be
+	// purged between this check and the commit.
+	var stored []string
+	query = `SELECT service_name FROM chunk_blobs
+             WHERE chunk_hash = $1 AND service_name = ANY($2) AND stored;`
+	rows, err := tx.Query(query, metadata.StoredHash, pq.Array(metadata.Replicas))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var serviceName string
+		if err := rows.Scan(&serviceName); err != nil {
+			return nil, err
+		}
+		stored = append(stored, serviceName)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stored, tx.Commit()
 }
 
-func (m *Manager) SaveChunkMetadata(metadata ChunkMetadata) error {
+// GetChunkMetadata returns the data chunks of a file in order. Parity chunks
+// are only loaded when a stripe has to be reconstructed.
+func (m *Manager) GetChunkMetadata(fileID int64) ([]ChunkMetadata, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	query := `INSERT INTO chunks (file_id, chunk_number, service_name, chunk_size, chunk_hash)
-              VALUES ($1, $2, $3, $4, $5);`
-	_, err := m.DB.Exec(query, metadata.FileID, metadata.ChunkNumber, metadata.ServiceName, metadata.ChunkSize, metadata.ChunkHash)
-	return err
+	return m.queryChunks(`c.file_id = $1 AND NOT c.is_parity`, fileID)
 }
 
-func (m *Manager) GetChunkMetadata(fileID int64) ([]ChunkMetadata, error) {
+// GetStripeChunkMetadata returns every data and parity chunk of the stripe
+// the given chunk belongs to, ordered by shard index. The chunk may belong
+// to a file or to a part of an unfinished multipart upload.
+func (m *Manager) GetStripeChunkMetadata(metadata ChunkMetadata) ([]ChunkMetadata, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	query := `SELECT id, chunk_number, service_name, chunk_size, chunk_hash FROM chunks WHERE file_id = $1 ORDER BY chunk_number ASC;`
-	rows, err := m.DB.Query(query, fileID)
+	if metadata.FileID == 0 {
+		return m.queryChunks(`c.part_id = $1 AND c.stripe_number = $2`, metadata.PartID, metadata.StripeNumber)
+	}
+	return m.queryChunks(`c.file_id = $1 AND c.stripe_number = $2`, metadata.FileID, metadata.StripeNumber)
+}
+
+// queryChunks loads the chunks matching condition along with their replica
+// locations.
+func (m *Manager) queryChunks(condition string, args ...interface{}) ([]ChunkMetadata, error) {
+	query := `SELECT ` + chunkColumns + `
+              FROM chunks c
+              LEFT JOIN chunk_replicas r ON r.chunk_id = c.id
+              WHERE ` + condition + `
+              GROUP BY c.id
+              ORDER BY c.chunk_number ASC, c.shard_index ASC;`
+	rows, err := m.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanChunks(rows)
+}
+
+// chunksETag computes an ETag from the hashes of the data chunks, in order.
+// It is the only place ETags of objects and parts are computed.
+const chunksETag = `'"' || left(encode(sha256(convert_to(COALESCE(string_agg(chunk_hash, '' ORDER BY chunk_number, shard_index), ''), 'UTF8')), 'hex'), 32) || '"'`
+
+const fileETagQuery = `SELECT ` + chunksETag + ` FROM chunks WHERE file_id = $1 AND NOT is_parity`
+
+const partETagQuery = `SELECT ` + chunksETag + ` FROM chunks WHERE part_id = $1 AND NOT is_parity`
+
+const chunkColumns = `c.id, COALESCE(c.file_id, 0), COALESCE(c.part_id, 0), c.chunk_number, c.chunk_size, c.chunk_hash,
+                     c.compression, c.stored_hash, c.stored_size, c.encrypted, c.is_parity, COALESCE(c.stripe_number, -1), COALESCE(c.shard_index, 0),
+                     array_remove(array_agg(r.service_name ORDER BY r.replica_index), NULL)`
+
+func scanChunks(rows *sql.Rows) ([]ChunkMetadata, error) {
 	var metadataList []ChunkMetadata
 	for rows.Next() {
 		var metadata ChunkMetadata
-		metadata.FileID = fileID
-		err := rows.Scan(&metadata.ID, &metadata.ChunkNumber, &metadata.ServiceName, &metadata.ChunkSize, &metadata.ChunkHash)
+		err := rows.Scan(&metadata.ID, &metadata.FileID, &metadata.PartID, &metadata.ChunkNumber, &metadata.ChunkSize, &metadata.ChunkHash,
+			&metadata.Compression, &metadata.StoredHash, &metadata.StoredSize, &metadata.Encrypted, &metadata.IsParity, &metadata.StripeNumber, &metadata.ShardIndex, pq.Array(&metadata.Replicas))
 		if err != nil {
 			return nil, err
 		}
 		metadataList = append(metadataList, metadata)
 	}
 
-	return metadataList, nil
+	return metadataList, rows.Err()
 }
 
-func (m *Manager) GetFileMetadata(filename string) (*FileMetadata, error) {
+func (m *Manager) GetFileMetadata(bucketID int64, filename string) (*FileMetadata, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	query := `SELECT id, total_chunks, total_size FROM files WHERE filename = $1;`
-	row := m.DB.QueryRow(query, filename)
+	query := `SELECT id, total_chunks, total_size, content_type, encryption, COALESCE(master_key_id, ''), wrapped_key, COALESCE(etag, ''), created_at, updated_at
+              FROM files WHERE bucket_id = $1 AND filename = $2;`
+	row := m.DB.QueryRow(query, bucketID, filename)
 
 	var metadata FileMetadata
+	metadata.BucketID = bucketID
 	metadata.Filename = filename
-	err := row.Scan(&metadata.ID, &metadata.TotalChunks, &metadata.TotalSize)
+	err := row.Scan(&metadata.ID, &metadata.TotalChunks, &metadata.TotalSize, &metadata.ContentType,
+		&metadata.DataKey.Encryption, &metadata.DataKey.MasterKeyID, &metadata.DataKey.Wrapped, &metadata.ETag, &metadata.CreatedAt, &metadata.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &metadata, nil
 }
 
-func (m *Manager) DeleteFileMetadata(fileID int64) error {
+func (m *Manager) ListFiles(bucketID int64, prefix, startAfter string, limit int) ([]FileMetadata, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	queryChunks := `DELETE FROM chunks WHERE file_id = $1;`
-	queryFiles := `DELETE FROM files WHERE id = $1;`
+	query := `SELECT id, filename, total_chunks, total_size, content_type, COALESCE(etag, ''), created_at, updated_at FROM files
+              WHERE bucket_id = $1 AND filename COLLATE "C" LIKE $2 ESCAPE '\' AND filename COLLATE "C" > $3
+              ORDER BY filename COLLATE "C" ASC
+              LIMIT $4;`
+	rows, err := m.DB.Query(query, bucketID, escapeLike(prefix)+"%", startAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []FileMetadata
+	for rows.Next() {
+		var metadata FileMetadata
+		metadata.BucketID = bucketID
+		err := rows.Scan(&metadata.ID, &metadata.Filename, &metadata.TotalChunks, &metadata.TotalSize, &metadata.ContentType, &metadata.ETag, &metadata.CreatedAt, &metadata.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, metadata)
+	}
 
-	_, err := m.DB.Exec(queryChunks, fileID)
+	return files, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// DeleteFileMetadata removes the file and its chunk rows in one transaction.
+// The chunk files themselves are queued for purging by the database.
+func (m *Manager) DeleteFileMetadata(fileID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.DB.Begin()
 	if err != nil {
 		return err
 	}
-	_, err = m.DB.Exec(queryFiles, fileID)
-	return err
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM chunks WHERE file_id = $1;`, fileID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM files WHERE id = $1;`, fileID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (m *Manager) Close() error {
 	return m.DB.Close()
 }
+
+func nullableID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,72 @@
+package storage
+
+import "strings"
+
+const listBatchSize = 1000
+
+// afterPrefix sorts after every key that starts with the prefix, so it can
+// be used as a marker to skip a whole common prefix.
+const afterPrefix = "\U0010FFFF"
+
+// ObjectListing is one page of a bucket listing. Keys that contain the
+// delimiter after the prefix are rolled up into CommonPrefixes.
+type ObjectListing struct {
+	Files          []FileMetadata
+	CommonPrefixes []string
+	IsTruncated    bool
+	NextMarker     string
+}
+
+func (l *ObjectListing) Count() int {
+	return len(l.Files) + len(l.CommonPrefixes)
+}
+
+// ListObjects returns up to maxKeys files and common prefixes in byte order,
+// starting after the given marker. NextMarker is set when the listing is
+// truncated and is what the following page should start after.
+func (m *Manager) ListObjects(bucketID int64, prefix, delimiter, marker string, maxKeys int) (*ObjectListing, error) {
+	listing := &ObjectListing{}
+	next := marker
+
+	for {
+		files, err := m.ListFiles(bucketID, prefix, next, listBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		skipped := false
+		for _, file := range files {
+			commonPrefix := ""
+			if delimiter != "" {
+				if i := strings.Index(file.Filename[len(prefix):], delimiter); i >= 0 {
+					commonPrefix = file.Filename[:len(prefix)+i+len(delimiter)]
+				}
+			}
+
+			if commonPrefix != "" && len(listing.CommonPrefixes) > 0 && listing.CommonPrefixes[len(listing.CommonPrefixes)-1] == commonPrefix {
+				next = file.Filename
+				continue
+			}
+
+			if listing.Count() == maxKeys {
+				listing.IsTruncated = true
+				listing.NextMarker = next
+				return listing, nil
+			}
+
+			if commonPrefix != "" {
+				listing.CommonPrefixes = append(listing.CommonPrefixes, commonPrefix)
+				next = commonPrefix + afterPrefix
+				skipped = true
+				break
+			}
+
+			listing.Files = append(listing.Files, file)
+			next = file.Filename
+		}
+
+		if !skipped && len(files) < listBatchSize {
+			return listing, nil
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"time"
+)
+
+type MultipartUpload struct {
+	ID          int64
+	UploadID    string
+	BucketID    int64
+	Filename    string
+	ContentType string
+	DataKey     DataKey
+	CreatedAt   time.Time
+}
+
+type MultipartPart struct {
+	ID          int64
+	UploadID    int64
+	PartNumber  int32
+	TotalChunks int32
+	TotalSize   int64
+	ETag        string
+	CreatedAt   time.Time
+}
+
+func (m *Manager) CreateMultipartUpload(bucketID int64, filename, contentType, uploadID string, dataKey DataKey) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var id int64
+	query := `INSERT INTO multipart_uploads (upload_id, bucket_id, filename, content_type, encryption, master_key_id, wrapped_key)
+              VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+              RETURNING id;`
+	err := m.DB.QueryRow(query, uploadID, bucketID, filename, contentType,
+		dataKey.Encryption, dataKey.MasterKeyID, dataKey.Wrapped).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (m *Manager) GetMultipartUpload(uploadID string) (*MultipartUpload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `SELECT id, upload_id, bucket_id, filename, content_type, encryption, COALESCE(master_key_id, ''), wrapped_key, created_at
+              FROM multipart_uploads WHERE upload_id = $1;`
+	var upload MultipartUpload
+	err := m.DB.QueryRow(query, uploadID).Scan(&upload.ID, &upload.UploadID, &upload.BucketID, &upload.Filename, &upload.ContentType,
+		&upload.DataKey.Encryption, &upload.DataKey.MasterKeyID, &upload.DataKey.Wrapped, &upload.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// CreatePart registers a new part, replacing any previous part with the same
+// number together with its chunks.
+func (m *Manager) CreatePart(uploadID int64, partNumber int32) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM multipart_parts WHERE upload_id = $1 AND part_number = $2;`, uploadID, partNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	var partID int64
+	query := `INSERT INTO multipart_parts (upload_id, part_number)
+              VALUES ($1, $2)
+              RETURNING id;`
+	err = tx.QueryRow(query, uploadID, partNumber).Scan(&partID)
+	if err != nil {
+		return 0, err
+	}
+
+	return partID, tx.Commit()
+}
+
+// UpdatePart records the size of an uploaded part and returns its ETag,
+// computed from the chunks stored for it.
+func (m *Manager) UpdatePart(partID int64, totalChunks int32, totalSize int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var etag string
+	query := `UPDATE multipart_parts SET total_chunks = $2, total_size = $3, etag = (` + partETagQuery + `)
+              WHERE id = $1
+              RETURNING etag;`
+	err := m.DB.QueryRow(query, partID, totalChunks, totalSize).Scan(&etag)
+	return etag, err
+}
+
+func (m *Manager) DeletePart(partID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.DB.Exec(`DELETE FROM multipart_parts WHERE id = $1;`, partID)
+	return err
+}
+
+// ListParts returns the parts that finished uploading, ordered by number.
+func (m *Manager) ListParts(uploadID int64) ([]MultipartPart, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `SELECT id, upload_id, part_number, total_chunks, total_size, etag, created_at
+              FROM multipart_parts
+              WHERE upload_id = $1 AND etag <> ''
+              ORDER BY part_number ASC;`
+	rows, err := m.DB.Query(query, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []MultipartPart
+	for rows.Next() {
+		var part MultipartPart
+		err := rows.Scan(&part.ID, &part.UploadID, &part.PartNumber, &part.TotalChunks, &part.TotalSize, &part.ETag, &part.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	return parts, rows.Err()
+}
+
+// CompleteMultipartUpload assembles the object from the given parts by
+// re-pointing their chunks at a new files row, replacing any existing object
+// with the same name. No chunk data is copied. It returns the ID and the
+// ETag of the new object.
+func (m *Manager) CompleteMultipartUpload(upload *MultipartUpload, parts []MultipartPart) (int64, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM files WHERE bucket_id = $1 AND filename = $2;`, upload.BucketID, upload.Filename)
+	if err != nil {
+		return 0, "", err
+	}
+
+	totalChunks := int32(0)
+	totalSize := int64(0)
+	for _, part := range parts {
+		totalChunks += part.TotalChunks
+		totalSize += part.TotalSize
+	}
+
+	var fileID int64
+	query := `INSERT INTO files (bucket_id, filename, content_type, total_chunks, total_size, encryption, master_key_id, wrapped_key)
+              VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+              RETURNING id;`
+	err = tx.QueryRow(query, upload.BucketID, upload.Filename, upload.ContentType, totalChunks, totalSize,
+		upload.DataKey.Encryption, upload.DataKey.MasterKeyID, upload.DataKey.Wrapped).Scan(&fileID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	offset := int32(0)
+	for _, part := range parts {
+		query := `UPDATE chunks SET file_id = $1, part_id = NULL, chunk_number = chunk_number + $2, stripe_number = stripe_number + $2
+                  WHERE part_id = $3;`
+		_, err = tx.Exec(query, fileID, offset, part.ID)
+		if err != nil {
+			return 0, "", err
+		}
+		offset += part.TotalChunks
+	}
+
+	var etag string
+	err = tx.QueryRow(`UPDATE files SET etag = (`+fileETagQuery+`) WHERE id = $1 RETURNING etag;`, fileID).Scan(&etag)
+	if err != nil {
+		return 0, "", err
+	}
+
+	_, err = tx.Exec(`DELETE FROM multipart_uploads WHERE id = $1;`, upload.ID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return fileID, etag, tx.Commit()
+}
+
+// HasMultipartUploads reports whether the bucket has unfinished multipart
+// uploads, including resumable uploads and objects still being written.
+func (m *Manager) HasMultipartUploads(bucketID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var exists bool
+	err := m.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM multipart_uploads WHERE bucket_id = $1);`, bucketID).Scan(&exists)
+	return exists, err
+}
+
+// ExtendMultipartUpload sets the time after which AbortExpiredUploads
+// aborts the upload. Only resumable uploads expire, together with their
+// session.
+func (m *Manager) ExtendMultipartUpload(uploadID int64, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `UPDATE multipart_uploads SET expires_at = now() + $2 * interval '1 second' WHERE id = $1;`
+	_, err := m.DB.Exec(query, uploadID, ttl.Seconds())
+	return err
+}
+
+// AbortExpiredUploads aborts the uploads whose session has expired. Their
+// chunks are queued for purging like those of any aborted upload.
+func (m *Manager) AbortExpiredUploads() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result, err := m.DB.Exec(`DELETE FROM multipart_uploads WHERE expires_at < now();`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (m *Manager) AbortMultipartUpload(uploadID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.DB.Exec(`DELETE FROM multipart_uploads WHERE id = $1;`, uploadID)
+	return err
+}
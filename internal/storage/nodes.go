@@ -0,0 +1,68 @@
+package storage
+
+import "time"
+
+// StorageNode is a registered storage node as persisted for reconnecting
+// after a restart of the transfer service.
+type StorageNode struct {
+	ServiceName  string
+	GRPCAddress  string
+	Weight       float64
+	Draining     bool
+	RegisteredAt time.Time
+	UpdatedAt    time.Time
+}
+
+func (m *Manager) SaveStorageNode(serviceName, grpcAddress string, weight float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `INSERT INTO storage_nodes (service_name, grpc_address, weight)
+              VALUES ($1, $2, $3)
+              ON CONFLICT (service_name) DO UPDATE
+              SET grpc_address = EXCLUDED.grpc_address, weight = EXCLUDED.weight, updated_at = now();`
+	_, err := m.DB.Exec(query, serviceName, grpcAddress, weight)
+	return err
+}
+
+func (m *Manager) SetStorageNodeDraining(serviceName string, draining bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.DB.Exec(`UPDATE storage_nodes SET draining = $1, updated_at = now() WHERE service_name = $2;`, draining, serviceName)
+	return err
+}
+
+func (m *Manager) DeleteStorageNode(serviceName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.DB.Exec(`DELETE FROM storage_nodes WHERE service_name = $1;`, serviceName)
+	return err
+}
+
+func (m *Manager) ListStorageNodes() ([]StorageNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `SELECT service_name, grpc_address, weight, draining, registered_at, updated_at
+              FROM storage_nodes
+              ORDER BY service_name;`
+
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []StorageNode
+	for rows.Next() {
+		var node StorageNode
+		if err := rows.Scan(&node.ServiceName, &node.GRPCAddress, &node.Weight, &node.Draining, &node.RegisteredAt, &node.UpdatedAt); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	return nodes, rows.Err()
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PurgeEntry is a chunk file whose reference count dropped to zero and that
+// has to be removed from its storage node. Entries are queued by a trigger
+// on the chunk_replicas table.
+type PurgeEntry struct {
+	ID          int64
+	ServiceName string
+	ChunkHash   string
+	QueuedAt    time.Time
+}
+
+func (m *Manager) ListPurgeQueue(afterID int64, limit int) ([]PurgeEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `SELECT id, service_name, chunk_hash, queued_at
+              FROM chunk_purge_queue
+              WHERE id > $1
+              ORDER BY id
+              LIMIT $2;`
+
+	rows, err := m.DB.Query(query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []PurgeEntry
+	for rows.Next() {
+		var entry PurgeEntry
+		if err := rows.Scan(&entry.ID, &entry.ServiceName, &entry.ChunkHash, &entry.QueuedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// PurgeClaim marks a purge queue entry whose chunk file is being deleted.
+// The trigger that references a chunk file rejects files marked this way, so
+// no upload can reuse the file until the claim is finished or released.
+type PurgeClaim struct {
+	m       *Manager
+	entryID int64
+	// Referenced is set if the file has been referenced again since it was
+	// queued. It must be kept then, and the entry has already been removed.
+	Referenced bool
+}
+
+// ClaimPurge marks the queue entry as being deleted, or returns nil if it
+// has already been removed. The mark is committed before returning, so no
+// lock is held while the file is deleted from its node. An entry left marked
+// by a purger that stopped halfway is claimed again on the next pass.
+func (m *Manager) ClaimPurge(entry PurgeEntry) (*PurgeClaim, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	claim := &PurgeClaim{m: m, entryID: entry.ID}
+	err = tx.QueryRow(`SELECT id FROM chunk_purge_queue WHERE id = $1 FOR UPDATE;`, entry.ID).Scan(&claim.entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT EXISTS (SELECT 1 FROM chunk_blobs WHERE service_name = $1 AND chunk_hash = $2 AND ref_count > 0);`
+	if err := tx.QueryRow(query, entry.ServiceName, entry.ChunkHash).Scan(&claim.Referenced); err != nil {
+		return nil, err
+	}
+
+	if claim.Referenced {
+		query = `DELETE FROM chunk_purge_queue WHERE id = $1;`
+	} else {
+		query = `UPDATE chunk_purge_queue SET deleting_since = now() WHERE id = $1;`
+	}
+	if _, err := tx.Exec(query, entry.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// Finish removes the entry from the queue once its file has been deleted.
+func (c *PurgeClaim) Finish() error {
+	if c.Referenced {
+		return nil
+	}
+
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	_, err := c.m.DB.Exec(`DELETE FROM chunk_purge_queue WHERE id = $1;`, c.entryID)
+	return err
+}
+
+// Release keeps the entry queued for the next pass and lets uploads
+// reference its file again.
+func (c *PurgeClaim) Release() error {
+	if c.Referenced {
+		return nil
+	}
+
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	_, err := c.m.DB.Exec(`UPDATE chunk_purge_queue SET deleting_since = NULL WHERE id = $1;`, c.entryID)
+	return err
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// RemoveChunkReplicas drops every replica of the chunk file stored on the
+// given node, for instance because the node found it corrupt. The chunks
+// themselves stay, so their remaining replicas keep serving reads.
+func (m *Manager) RemoveChunkReplicas(serviceName, chunkHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result, err := m.DB.Exec(`DELETE FROM chunk_replicas WHERE service_name = $1 AND chunk_hash = $2;`, serviceName, chunkHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListChunks returns up to limit chunks with an id above afterID, in id
+// order, including parity chunks and chunks of unfinished multipart uploads.
+func (m *Manager) ListChunks(afterID int64, limit int) ([]ChunkMetadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `SELECT ` + chunkColumns + `
+              FROM chunks c
+              LEFT JOIN chunk_replicas r ON r.chunk_id = c.id
+              WHERE c.id > $1
+              GROUP BY c.id
+              ORDER BY c.id ASC
+              LIMIT $2;`
+	rows, err := m.DB.Query(query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanChunks(rows)
+}
+
+// GetChunk returns the chunk with its current replicas, or sql.ErrNoRows if
+// it has been deleted.
+func (m *Manager) GetChunk(chunkID int64) (*ChunkMetadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chunks, err := m.queryChunks(`c.id = $1`, chunkID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &chunks[0], nil
+}
+
+// AddChunkReplica records a new copy of a chunk, after the existing ones in
+// preference order. Adding a replica that is already recorded does nothing.
+// It is recorded before the copy is written, so that the chunk file is
+// referenced and cannot be purged while it is being written; the copy
+// counts as stored only once MarkChunkBlobStored is called.
+func (m *Manager) AddChunkReplica(chunkID int64, serviceName, storedHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `INSERT INTO chunk_replicas (chunk_id, service_name, chunk_hash, replica_index)
+              SELECT $1, $2, $3, COALESCE(MAX(replica_index) + 1, 0)
+              FROM chunk_replicas
+              WHERE chunk_id = $1
+              ON CONFLICT (chunk_id, service_name) DO NOTHING;`
+	_, err := m.DB.Exec(query, chunkID, serviceName, storedHash)
+	return err
+}
+
+// ListNodeChunks returns up to limit chunks with an id above afterID that
+// have a replica on the given node, in id order.
+func (m *Manager) ListNodeChunks(serviceName string, afterID int64, limit int) ([]ChunkMetadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `SELECT ` + chunkColumns + `
+              FROM chunks c
+              LEFT JOIN chunk_replicas r ON r.chunk_id = c.id
+              WHERE c.id > $2 AND c.id IN (SELECT chunk_id FROM chunk_replicas WHERE service_name = $1)
+              GROUP BY c.id
+              ORDER BY c.id ASC
+              LIMIT $3;`
+	rows, err := m.DB.Query(query, serviceName, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanChunks(rows)
+}
+
+// RemoveChunkReplica drops one replica of a chunk. Its file is purged from
+// the node unless another replica still uses it.
+func (m *Manager) RemoveChunkReplica(chunkID int64, serviceName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.DB.Exec(`DELETE FROM chunk_replicas WHERE chunk_id = $1 AND service_name = $2;`, chunkID, serviceName)
+	return err
+}
+
+func (m *Manager) CountNodeReplicas(serviceName string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM chunk_replicas WHERE service_name = $1;`, serviceName).Scan(&count)
+	return count, err
+}
+
+// MoveChunkReplica replaces the replica of a chunk on one node by the
+// replica on another that AddChunkReplica recorded, keeping the preference
+// order of the old one. The new copy must have been written by now; the old
+// one is purged once nothing else references it.
+func (m *Manager) MoveChunkReplica(chunkID int64, fromService, toService, storedHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var replicaIndex int32
+	query := `DELETE FROM chunk_replicas WHERE chunk_id = $1 AND service_name = $2 RETURNING replica_index;`
+	err = tx.QueryRow(query, chunkID, fromService).Scan(&replicaIndex)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("chunk %d has no replica on %s", chunkID, fromService)
+	}
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE chunk_replicas SET replica_index = $3 WHERE chunk_id = $1 AND service_name = $2;`
+	result, err := tx.Exec(query, chunkID, toService, replicaIndex)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated == 0 {
+		return fmt.Errorf("chunk %d has no replica on %s", chunkID, toService)
+	}
+	if err := markBlobStored(tx, toService, storedHash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrSessionNotFound = errors.New("upload session not found")
+
+// UploadSession tracks a resumable upload. The data itself lives in a
+// multipart upload; the session only remembers how far the client got.
+type UploadSession struct {
+	ID        string
+	UploadID  string
+	Length    int64
+	Offset    int64
+	Parts     int32
+	Chunking  string
+	Completed bool
+}
+
+type SessionStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewSessionStore(addr string, ttl time.Duration) *SessionStore {
+	return &SessionStore{
+		client: redis.NewClient(&redis.Options{Addr: addr}),
+		ttl:    ttl,
+	}
+}
+
+// TTL is how long a session survives without requests.
+func (s *SessionStore) TTL() time.Duration {
+	return s.ttl
+}
+
+func sessionKey(id string) string {
+	return "upload_session:" + id
+}
+
+func (s *SessionStore) Save(ctx context.Context, session *UploadSession) error {
+	key := sessionKey(session.ID)
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, map[string]interface{}{
+			"upload_id": session.UploadID,
+			"length":    session.Length,
+			"offset":    session.Offset,
+			"parts":     session.Parts,
+			"chunking":  session.Chunking,
+			"completed": session.Completed,
+		})
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *SessionStore) Get(ctx context.Context, id string) (*UploadSession, error) {
+	values, err := s.client.HGetAll(ctx, sessionKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	session := &UploadSession{
+		ID:       id,
+		UploadID: values["upload_id"],
+		Chunking: values["chunking"],
+	}
+	if session.Length, err = strconv.ParseInt(values["length"], 10, 64); err != nil {
+		return nil, err
+	}
+	if session.Offset, err = strconv.ParseInt(values["offset"], 10, 64); err != nil {
+		return nil, err
+	}
+	parts, err := strconv.ParseInt(values["parts"], 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	session.Parts = int32(parts)
+	session.Completed = values["completed"] == "1"
+
+	return session, nil
+}
+
+func (s *SessionStore) Delete(ctx context.Context, id string) error {
+	return s.client.Del(ctx, sessionKey(id)).Err()
+}
+
+// sessionLockTTL bounds how long a session stays locked after the server
+// holding the lock dies. A live holder renews the lock well before that.
+const sessionLockTTL = 30 * time.Second
+
+var (
+	renewLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// SessionLock is held by the one request that appends to a session.
+type SessionLock struct {
+	client *redis.Client
+	key    string
+	token  string
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Lock makes sure only one request appends to a session at a time. It
+// returns nil if the session is already locked. The lock is renewed in the
+// background until Unlock is called.
+func (s *SessionStore) Lock(ctx context.Context, id string) (*SessionLock, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	lock := &SessionLock{
+		client: s.client,
+		key:    sessionKey(id) + ":lock",
+		token:  hex.EncodeToString(b),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	locked, err := s.client.SetNX(ctx, lock.key, lock.token, sessionLockTTL).Result()
+	if err != nil || !locked {
+		return nil, err
+	}
+
+	go lock.renew()
+	return lock, nil
+}
+
+func (l *SessionLock) renew() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(sessionLockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			renewed, err := renewLockScript.Run(context.Background(), l.client, []string{l.key}, l.token, sessionLockTTL.Milliseconds()).Int()
+			if err == nil && renewed == 0 {
+				// The lock expired and may have been taken by another request.
+				return
+			}
+		}
+	}
+}
+
+// Unlock releases the lock unless it has expired and been taken by another
+// request in the meantime.
+func (l *SessionLock) Unlock(ctx context.Context) error {
+	close(l.stop)
+	<-l.done
+	return releaseLockScript.Run(ctx, l.client, []string{l.key}, l.token).Err()
+}
+
+func (s *SessionStore) Close() error {
+	return s.client.Close()
+}
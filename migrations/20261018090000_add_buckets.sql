@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Создаем таблицу buckets для S3-совместимого API
+CREATE TABLE IF NOT EXISTS buckets (
+                                       id SERIAL PRIMARY KEY,
+                                       name TEXT NOT NULL UNIQUE,
+                                       created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Добавляем время создания файла, нужное для Last-Modified
+ALTER TABLE files ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE files DROP COLUMN IF EXISTS created_at;
+DROP TABLE IF EXISTS buckets;
+
+-- +goose StatementEnd
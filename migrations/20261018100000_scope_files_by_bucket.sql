@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Расширяем buckets владельцем и настройками
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}';
+
+INSERT INTO buckets (name) VALUES ('default') ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE files ADD COLUMN IF NOT EXISTS bucket_id INTEGER REFERENCES buckets (id);
+
+-- Объекты, созданные через S3 API, хранились под именем "<bucket>/<key>"
+UPDATE files f
+SET bucket_id = b.id,
+    filename = substr(f.filename, length(b.name) + 2)
+FROM buckets b
+WHERE f.bucket_id IS NULL
+  AND b.name <> 'default'
+  AND f.filename LIKE b.name || '/%';
+
+-- Остальные файлы переносим в бакет по умолчанию
+UPDATE files
+SET bucket_id = (SELECT id FROM buckets WHERE name = 'default')
+WHERE bucket_id IS NULL;
+
+ALTER TABLE files ALTER COLUMN bucket_id SET NOT NULL;
+ALTER TABLE files DROP CONSTRAINT IF EXISTS files_filename_key;
+ALTER TABLE files ADD CONSTRAINT files_bucket_id_filename_key UNIQUE (bucket_id, filename);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE files DROP CONSTRAINT IF EXISTS files_bucket_id_filename_key;
+
+UPDATE files f
+SET filename = b.name || '/' || f.filename
+FROM buckets b
+WHERE f.bucket_id = b.id
+  AND b.name <> 'default';
+
+ALTER TABLE files ADD CONSTRAINT files_filename_key UNIQUE (filename);
+ALTER TABLE files DROP COLUMN IF EXISTS bucket_id;
+
+ALTER TABLE buckets DROP COLUMN IF EXISTS settings;
+ALTER TABLE buckets DROP COLUMN IF EXISTS owner;
+
+-- +goose StatementEnd
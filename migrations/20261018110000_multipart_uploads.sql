@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Незавершенные multipart-загрузки S3
+CREATE TABLE IF NOT EXISTS multipart_uploads (
+                                                 id SERIAL PRIMARY KEY,
+                                                 upload_id TEXT NOT NULL UNIQUE,
+                                                 bucket_id INTEGER NOT NULL REFERENCES buckets (id),
+                                                 filename TEXT NOT NULL,
+                                                 created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS multipart_parts (
+                                               id SERIAL PRIMARY KEY,
+                                               upload_id INTEGER NOT NULL,
+                                               part_number INTEGER NOT NULL,
+                                               total_chunks INTEGER NOT NULL DEFAULT 0,
+                                               total_size BIGINT NOT NULL DEFAULT 0,
+                                               etag TEXT NOT NULL DEFAULT '',
+                                               created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                               UNIQUE (upload_id, part_number),
+                                               FOREIGN KEY (upload_id) REFERENCES multipart_uploads (id) ON DELETE CASCADE
+);
+
+-- Чанки части принадлежат части до завершения загрузки, затем переносятся в файл
+ALTER TABLE chunks ALTER COLUMN file_id DROP NOT NULL;
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS part_id INTEGER REFERENCES multipart_parts (id) ON DELETE CASCADE;
+ALTER TABLE chunks ADD CONSTRAINT chunks_owner_check CHECK (file_id IS NOT NULL OR part_id IS NOT NULL);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DELETE FROM chunks WHERE file_id IS NULL;
+ALTER TABLE chunks DROP CONSTRAINT IF EXISTS chunks_owner_check;
+ALTER TABLE chunks DROP COLUMN IF EXISTS part_id;
+ALTER TABLE chunks ALTER COLUMN file_id SET NOT NULL;
+
+DROP TABLE IF EXISTS multipart_parts;
+DROP TABLE IF EXISTS multipart_uploads;
+
+-- +goose StatementEnd
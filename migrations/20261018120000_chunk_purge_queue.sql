@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Очередь чанков, которые нужно удалить с узлов хранения
+CREATE TABLE IF NOT EXISTS chunk_purge_queue (
+                                                 id SERIAL PRIMARY KEY,
+                                                 service_name TEXT NOT NULL,
+                                                 chunk_hash TEXT NOT NULL,
+                                                 queued_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Поиск других ссылок на тот же файл чанка перед удалением
+CREATE INDEX IF NOT EXISTS chunks_service_hash_idx ON chunks (service_name, chunk_hash);
+
+-- Любое удаление строки чанка (в том числе каскадное) ставит его файл в очередь
+CREATE OR REPLACE FUNCTION queue_chunk_purge() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO chunk_purge_queue (service_name, chunk_hash) VALUES (OLD.service_name, OLD.chunk_hash);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER chunks_queue_purge
+    AFTER DELETE ON chunks
+    FOR EACH ROW EXECUTE FUNCTION queue_chunk_purge();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TRIGGER IF EXISTS chunks_queue_purge ON chunks;
+DROP FUNCTION IF EXISTS queue_chunk_purge();
+DROP INDEX IF EXISTS chunks_service_hash_idx;
+DROP TABLE IF EXISTS chunk_purge_queue;
+
+-- +goose StatementEnd
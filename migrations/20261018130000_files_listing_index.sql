@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Время последнего изменения файла
+ALTER TABLE files ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+UPDATE files SET updated_at = created_at;
+
+-- Индекс для постраничного листинга файлов бакета по префиксу в побайтовом порядке
+CREATE INDEX IF NOT EXISTS files_bucket_filename_c_idx ON files (bucket_id, filename COLLATE "C");
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS files_bucket_filename_c_idx;
+ALTER TABLE files DROP COLUMN IF EXISTS updated_at;
+
+-- +goose StatementEnd
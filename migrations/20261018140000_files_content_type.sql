@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- MIME-тип объекта, переданный при загрузке
+ALTER TABLE files ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT 'application/octet-stream';
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT 'application/octet-stream';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE multipart_uploads DROP COLUMN IF EXISTS content_type;
+ALTER TABLE files DROP COLUMN IF EXISTS content_type;
+
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Каждая копия чанка хранится на отдельном узле; хэш дублируется,
+-- чтобы очередь удаления работала и при каскадном удалении чанка
+CREATE TABLE IF NOT EXISTS chunk_replicas (
+                                              chunk_id INTEGER NOT NULL REFERENCES chunks (id) ON DELETE CASCADE,
+                                              service_name TEXT NOT NULL,
+                                              chunk_hash TEXT NOT NULL,
+                                              replica_index INTEGER NOT NULL DEFAULT 0,
+                                              PRIMARY KEY (chunk_id, service_name)
+);
+
+INSERT INTO chunk_replicas (chunk_id, service_name, chunk_hash)
+SELECT id, service_name, chunk_hash FROM chunks;
+
+CREATE INDEX IF NOT EXISTS chunk_replicas_service_hash_idx ON chunk_replicas (service_name, chunk_hash);
+
+-- Файлы чанков теперь ставятся в очередь удаления при удалении реплики
+DROP TRIGGER IF EXISTS chunks_queue_purge ON chunks;
+DROP INDEX IF EXISTS chunks_service_hash_idx;
+ALTER TABLE chunks DROP COLUMN IF EXISTS service_name;
+
+CREATE TRIGGER chunk_replicas_queue_purge
+    AFTER DELETE ON chunk_replicas
+    FOR EACH ROW EXECUTE FUNCTION queue_chunk_purge();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TRIGGER IF EXISTS chunk_replicas_queue_purge ON chunk_replicas;
+
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS service_name TEXT NOT NULL DEFAULT '';
+UPDATE chunks SET service_name = r.service_name
+FROM chunk_replicas r
+WHERE r.chunk_id = chunks.id AND r.replica_index = 0;
+ALTER TABLE chunks ALTER COLUMN service_name DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS chunks_service_hash_idx ON chunks (service_name, chunk_hash);
+
+CREATE TRIGGER chunks_queue_purge
+    AFTER DELETE ON chunks
+    FOR EACH ROW EXECUTE FUNCTION queue_chunk_purge();
+
+DROP TABLE IF EXISTS chunk_replicas;
+
+-- +goose StatementEnd
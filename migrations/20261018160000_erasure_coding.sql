@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Чанки в режиме erasure coding объединяются в страйпы из K чанков данных и M чанков четности.
+-- Номер страйпа равен номеру первого чанка данных в нем, shard_index - позиция чанка в страйпе
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS is_parity BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS stripe_number INTEGER;
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS shard_index INTEGER;
+
+CREATE INDEX IF NOT EXISTS chunks_file_stripe_idx ON chunks (file_id, stripe_number) WHERE stripe_number IS NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DELETE FROM chunks WHERE is_parity;
+DROP INDEX IF EXISTS chunks_file_stripe_idx;
+ALTER TABLE chunks DROP COLUMN IF EXISTS shard_index;
+ALTER TABLE chunks DROP COLUMN IF EXISTS stripe_number;
+ALTER TABLE chunks DROP COLUMN IF EXISTS is_parity;
+
+-- +goose StatementEnd
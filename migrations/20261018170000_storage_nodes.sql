@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Зарегистрированные узлы хранения, чтобы сервис передачи
+-- переподключался к ним после перезапуска
+CREATE TABLE IF NOT EXISTS storage_nodes (
+                                             service_name TEXT PRIMARY KEY,
+                                             grpc_address TEXT NOT NULL,
+                                             weight DOUBLE PRECISION NOT NULL DEFAULT 1,
+                                             registered_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                             updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TABLE IF EXISTS storage_nodes;
+
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Узел в режиме вывода из эксплуатации не получает новые чанки,
+-- а его чанки переносятся на другие узлы
+ALTER TABLE storage_nodes ADD COLUMN IF NOT EXISTS draining BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE storage_nodes DROP COLUMN IF EXISTS draining;
+
+-- +goose StatementEnd
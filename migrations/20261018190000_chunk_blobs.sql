@@ -0,0 +1,71 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Файл чанка на узле хранения, общий для всех реплик с тем же хэшем на этом узле.
+-- stored выставляется, когда файл точно записан на узел; только такие файлы
+-- можно не передавать повторно при загрузке
+CREATE TABLE IF NOT EXISTS chunk_blobs (
+                                           chunk_hash TEXT NOT NULL,
+                                           service_name TEXT NOT NULL,
+                                           ref_count INTEGER NOT NULL DEFAULT 0,
+                                           stored BOOLEAN NOT NULL DEFAULT FALSE,
+                                           created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+                                           PRIMARY KEY (chunk_hash, service_name)
+);
+
+INSERT INTO chunk_blobs (chunk_hash, service_name, ref_count, stored)
+SELECT chunk_hash, service_name, COUNT(*), TRUE FROM chunk_replicas
+GROUP BY chunk_hash, service_name;
+
+CREATE OR REPLACE FUNCTION acquire_chunk_blob() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO chunk_blobs (chunk_hash, service_name, ref_count)
+    VALUES (NEW.chunk_hash, NEW.service_name, 1)
+    ON CONFLICT (chunk_hash, service_name) DO UPDATE SET ref_count = chunk_blobs.ref_count + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Файл ставится в очередь удаления, только когда на него не осталось ссылок
+CREATE OR REPLACE FUNCTION release_chunk_blob() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE chunk_blobs SET ref_count = ref_count - 1
+    WHERE chunk_hash = OLD.chunk_hash AND service_name = OLD.service_name;
+
+    DELETE FROM chunk_blobs
+    WHERE chunk_hash = OLD.chunk_hash AND service_name = OLD.service_name AND ref_count <= 0;
+    IF FOUND THEN
+        INSERT INTO chunk_purge_queue (service_name, chunk_hash) VALUES (OLD.service_name, OLD.chunk_hash);
+    END IF;
+
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS chunk_replicas_queue_purge ON chunk_replicas;
+
+CREATE TRIGGER chunk_replicas_acquire_blob
+    AFTER INSERT ON chunk_replicas
+    FOR EACH ROW EXECUTE FUNCTION acquire_chunk_blob();
+
+CREATE TRIGGER chunk_replicas_release_blob
+    AFTER DELETE ON chunk_replicas
+    FOR EACH ROW EXECUTE FUNCTION release_chunk_blob();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP TRIGGER IF EXISTS chunk_replicas_release_blob ON chunk_replicas;
+DROP TRIGGER IF EXISTS chunk_replicas_acquire_blob ON chunk_replicas;
+DROP FUNCTION IF EXISTS release_chunk_blob();
+DROP FUNCTION IF EXISTS acquire_chunk_blob();
+
+CREATE TRIGGER chunk_replicas_queue_purge
+    AFTER DELETE ON chunk_replicas
+    FOR EACH ROW EXECUTE FUNCTION queue_chunk_purge();
+
+DROP TABLE IF EXISTS chunk_blobs;
+
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Чанк может храниться на узлах в сжатом виде. chunk_hash по-прежнему считается по исходным данным,
+-- а stored_hash - по байтам, записанным на узел; по нему называются файлы чанков на узлах и
+-- заполняется chunk_replicas.chunk_hash. Для несжатых чанков оба хэша совпадают
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS compression TEXT NOT NULL DEFAULT 'none';
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS stored_hash TEXT;
+
+UPDATE chunks SET stored_hash = chunk_hash WHERE stored_hash IS NULL;
+
+ALTER TABLE chunks ALTER COLUMN stored_hash SET NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE chunks DROP COLUMN IF EXISTS stored_hash;
+ALTER TABLE chunks DROP COLUMN IF EXISTS compression;
+
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Шифрование на стороне сервера: у каждого зашифрованного объекта свой ключ данных, который хранится
+-- только в обернутом виде - мастер-ключом KMS (encryption = 'kms', master_key_id - его идентификатор)
+-- или ключом клиента (encryption = 'customer', SSE-C)
+ALTER TABLE files ADD COLUMN IF NOT EXISTS encryption TEXT NOT NULL DEFAULT 'none';
+ALTER TABLE files ADD COLUMN IF NOT EXISTS master_key_id TEXT;
+ALTER TABLE files ADD COLUMN IF NOT EXISTS wrapped_key BYTEA;
+
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS encryption TEXT NOT NULL DEFAULT 'none';
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS master_key_id TEXT;
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS wrapped_key BYTEA;
+
+-- Чанк зашифрован ключом данных своего объекта. stored_size - размер байтов на узле;
+-- чанки четности erasure coding вычисляются по ним и не шифруются
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS encrypted BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS stored_size BIGINT;
+
+UPDATE chunks SET stored_size = chunk_size WHERE stored_size IS NULL;
+
+ALTER TABLE chunks ALTER COLUMN stored_size SET NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE chunks DROP COLUMN IF EXISTS stored_size;
+ALTER TABLE chunks DROP COLUMN IF EXISTS encrypted;
+
+ALTER TABLE multipart_uploads DROP COLUMN IF EXISTS wrapped_key;
+ALTER TABLE multipart_uploads DROP COLUMN IF EXISTS master_key_id;
+ALTER TABLE multipart_uploads DROP COLUMN IF EXISTS encryption;
+
+ALTER TABLE files DROP COLUMN IF EXISTS wrapped_key;
+ALTER TABLE files DROP COLUMN IF EXISTS master_key_id;
+ALTER TABLE files DROP COLUMN IF EXISTS encryption;
+
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Очистка удерживает блокировку строки очереди, пока удаляет файл чанка с узла. Новая ссылка
+-- на тот же файл ждет эту блокировку, иначе загрузка могла бы записать чанк, который тут же удалят
+CREATE INDEX IF NOT EXISTS chunk_purge_queue_service_hash_idx ON chunk_purge_queue (service_name, chunk_hash);
+
+CREATE OR REPLACE FUNCTION acquire_chunk_blob() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM 1 FROM chunk_purge_queue
+    WHERE service_name = NEW.service_name AND chunk_hash = NEW.chunk_hash
+    FOR SHARE;
+
+    INSERT INTO chunk_blobs (chunk_hash, service_name, ref_count)
+    VALUES (NEW.chunk_hash, NEW.service_name, 1)
+    ON CONFLICT (chunk_hash, service_name) DO UPDATE SET ref_count = chunk_blobs.ref_count + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+CREATE OR REPLACE FUNCTION acquire_chunk_blob() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO chunk_blobs (chunk_hash, service_name, ref_count)
+    VALUES (NEW.chunk_hash, NEW.service_name, 1)
+    ON CONFLICT (chunk_hash, service_name) DO UPDATE SET ref_count = chunk_blobs.ref_count + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS chunk_purge_queue_service_hash_idx;
+
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Срок жизни tus-загрузки: после истечения сессии в Redis загрузка отменяется, а ее чанки удаляются.
+-- У multipart-загрузок S3 срока нет
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS multipart_uploads_expires_at_idx ON multipart_uploads (expires_at) WHERE expires_at IS NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+DROP INDEX IF EXISTS multipart_uploads_expires_at_idx;
+ALTER TABLE multipart_uploads DROP COLUMN IF EXISTS expires_at;
+
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- ETag объекта считается по хэшам его чанков один раз при записи, чтобы листинг не читал чанки каждого объекта
+ALTER TABLE files ADD COLUMN IF NOT EXISTS etag TEXT;
+
+UPDATE files f
+SET etag = (SELECT '"' || left(encode(sha256(convert_to(COALESCE(string_agg(c.chunk_hash, '' ORDER BY c.chunk_number, c.shard_index), ''), 'UTF8')), 'hex'), 32) || '"'
+            FROM chunks c WHERE c.file_id = f.id AND NOT c.is_parity)
+WHERE etag IS NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE files DROP COLUMN IF EXISTS etag;
+
+-- +goose StatementEnd
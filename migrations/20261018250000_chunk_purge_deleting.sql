@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Очистка больше не держит блокировку строки очереди, пока удаляет файл чанка с узла: она
+-- отмечает строку временем начала удаления и фиксирует транзакцию. Новая ссылка на файл,
+-- который сейчас удаляется, отклоняется, загрузку можно повторить после очистки
+ALTER TABLE chunk_purge_queue ADD COLUMN IF NOT EXISTS deleting_since TIMESTAMPTZ;
+
+CREATE OR REPLACE FUNCTION acquire_chunk_blob() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM 1 FROM chunk_purge_queue
+    WHERE service_name = NEW.service_name AND chunk_hash = NEW.chunk_hash
+    FOR SHARE;
+
+    IF EXISTS (SELECT 1 FROM chunk_purge_queue
+               WHERE service_name = NEW.service_name AND chunk_hash = NEW.chunk_hash
+                 AND deleting_since IS NOT NULL) THEN
+        RAISE EXCEPTION 'chunk file % on % is being purged', NEW.chunk_hash, NEW.service_name;
+    END IF;
+
+    INSERT INTO chunk_blobs (chunk_hash, service_name, ref_count)
+    VALUES (NEW.chunk_hash, NEW.service_name, 1)
+    ON CONFLICT (chunk_hash, service_name) DO UPDATE SET ref_count = chunk_blobs.ref_count + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+CREATE OR REPLACE FUNCTION acquire_chunk_blob() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM 1 FROM chunk_purge_queue
+    WHERE service_name = NEW.service_name AND chunk_hash = NEW.chunk_hash
+    FOR SHARE;
+
+    INSERT INTO chunk_blobs (chunk_hash, service_name, ref_count)
+    VALUES (NEW.chunk_hash, NEW.service_name, 1)
+    ON CONFLICT (chunk_hash, service_name) DO UPDATE SET ref_count = chunk_blobs.ref_count + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE chunk_purge_queue DROP COLUMN IF EXISTS deleting_since;
+
+-- +goose StatementEnd